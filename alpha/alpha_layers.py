@@ -0,0 +1,91 @@
+"""Keras layers of the bot_alpha_zoo network.
+
+The input convolution uses valid padding so the residual tower and the heads
+see a 6x6 board. resnet.go implements the same network in Go.
+"""
+
+import tensorflow as tf
+
+from tensorflow.keras import layers
+
+from features import FEATURE_PLANES
+
+
+class AlphaConvolutionLayer(layers.Layer):
+
+    def __init__(self, filters, kernel_size, padding='valid', activation=None, name=None, **kwargs):
+        super(AlphaConvolutionLayer, self).__init__(name=name, **kwargs)
+        self.conv = layers.Conv2D(
+            filters, kernel_size, padding=padding, data_format='channels_last')
+        self.norm = layers.BatchNormalization(axis=3)
+        self.activation = None
+        if activation is not None:
+            self.activation = layers.Activation(activation)
+
+    def call(self, inputs):
+        x = self.conv(inputs)
+        x = self.norm(x)
+        if self.activation is None:
+            return x
+        return self.activation(x)
+
+
+class AlphaResidualLayer(layers.Layer):
+
+    def __init__(self, filters=256, **kwargs):
+        super(AlphaResidualLayer, self).__init__(**kwargs)
+        self.conv1 = AlphaConvolutionLayer(
+            filters, (3, 3), padding='same', activation='relu')
+        self.conv2 = AlphaConvolutionLayer(filters, (3, 3), padding='same')
+        self.add = layers.Add()
+        self.activation = layers.Activation('relu')
+
+    def call(self, inputs):
+        x = self.conv1(inputs)
+        x = self.conv2(x)
+        x = self.add([x, inputs])
+        return self.activation(x)
+
+
+class AlphaValueHead(layers.Layer):
+
+    def __init__(self, hidden=256, name=None, **kwargs):
+        super(AlphaValueHead, self).__init__(name=name, **kwargs)
+        self.conv = AlphaConvolutionLayer(1, (1, 1), activation='relu')
+        self.flatten = layers.Flatten(data_format='channels_last')
+        self.hidden = layers.Dense(hidden, activation='relu')
+        self.value_dense = layers.Dense(1, activation='tanh')
+
+    def call(self, inputs):
+        x = self.conv(inputs)
+        x = self.flatten(x)
+        x = self.hidden(x)
+        return self.value_dense(x)
+
+
+class AlphaPolicyHead(layers.Layer):
+
+    def __init__(self, hidden=256, name=None, **kwargs):
+        super(AlphaPolicyHead, self).__init__(name=name, **kwargs)
+        self.conv = AlphaConvolutionLayer(2, (1, 1), activation='relu')
+        self.flatten = layers.Flatten(data_format='channels_last')
+        self.dense = layers.Dense(hidden, activation='relu')
+        self.policy_output = layers.Dense(232, activation='linear')
+
+    def call(self, inputs):
+        x = self.conv(inputs)
+        x = self.flatten(x)
+        x = self.dense(x)
+        return self.policy_output(x)
+
+
+def build_model(depth=16, filters=256, hidden=256):
+    """Returns the bot_alpha_zoo network with depth residual layers."""
+    x = tf.keras.Input(shape=(8, 8, FEATURE_PLANES), name='x')
+    y = AlphaConvolutionLayer(filters, (3, 3), activation='relu', name='input_')(x)
+    for _ in range(depth):
+        y = AlphaResidualLayer(filters=filters)(y)
+    y1 = AlphaValueHead(hidden=hidden, name='value_')(y)
+    y2 = AlphaPolicyHead(hidden=hidden, name='policy_')(y)
+    return tf.keras.Model(inputs=x, outputs=(y1, y2),
+                          name='bot_alpha_zoo-{depth}'.format(depth=depth))
@@ -0,0 +1,65 @@
+"""Exports bot_alpha_zoo weights for the pure Go ResNet (resnet.go).
+
+The format is little endian: the magic "ZOOW", a uint32 version and uint32
+tensor count followed by the tensors. Each tensor is a uint32 name length and
+name, uint32 number of dims and the dims as uint32s followed by the float32
+data in row major order.
+"""
+
+import struct
+
+import numpy as np
+
+WEIGHTS_MAGIC = b'ZOOW'
+WEIGHTS_VERSION = 1
+
+
+def _conv_tensors(prefix, layer):
+    yield prefix + 'conv/kernel', layer.conv.kernel
+    yield prefix + 'conv/bias', layer.conv.bias
+    yield prefix + 'norm/gamma', layer.norm.gamma
+    yield prefix + 'norm/beta', layer.norm.beta
+    yield prefix + 'norm/moving_mean', layer.norm.moving_mean
+    yield prefix + 'norm/moving_variance', layer.norm.moving_variance
+
+
+def _dense_tensors(prefix, layer):
+    yield prefix + 'kernel', layer.kernel
+    yield prefix + 'bias', layer.bias
+
+
+def model_tensors(model):
+    """Yields the named weight tensors of the model in file order."""
+    residual = 0
+    for layer in model.layers:
+        if layer.name == 'input_':
+            yield from _conv_tensors('input_/', layer)
+        elif layer.name == 'value_':
+            yield from _conv_tensors('value_/', layer.conv)
+            yield from _dense_tensors('value_/hidden/', layer.hidden)
+            yield from _dense_tensors('value_/value_dense/', layer.value_dense)
+        elif layer.name == 'policy_':
+            yield from _conv_tensors('policy_/', layer.conv)
+            yield from _dense_tensors('policy_/dense/', layer.dense)
+            yield from _dense_tensors('policy_/policy_output/', layer.policy_output)
+        elif hasattr(layer, 'conv1'):
+            prefix = 'res_{}/'.format(residual)
+            yield from _conv_tensors(prefix + 'conv1/', layer.conv1)
+            yield from _conv_tensors(prefix + 'conv2/', layer.conv2)
+            residual += 1
+
+
+def export_weights(model, path):
+    """Writes the weights of the model to path."""
+    tensors = [(name, np.asarray(t, dtype='<f4'))
+               for name, t in model_tensors(model)]
+    with open(path, 'wb') as f:
+        f.write(WEIGHTS_MAGIC)
+        f.write(struct.pack('<II', WEIGHTS_VERSION, len(tensors)))
+        for name, t in tensors:
+            name = name.encode()
+            f.write(struct.pack('<I', len(name)))
+            f.write(name)
+            f.write(struct.pack('<I', t.ndim))
+            f.write(struct.pack('<{}I'.format(t.ndim), *t.shape))
+            f.write(t.tobytes())
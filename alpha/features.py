@@ -0,0 +1,4 @@
+"""Shapes of the model input and output shared with features.go and model.go."""
+
+# Number of input feature planes (featurePlanes in features.go).
+FEATURE_PLANES = 22
@@ -0,0 +1,234 @@
+"""Writes the golden weights and outputs for the ResNet tests (resnet_test.go).
+
+By default the bot_alpha_zoo-16 network of model.py is built with Tensorflow
+and evaluated on random inputs. Its weights are loaded from the training
+checkpoint given by --checkpoint, otherwise every weight including the batch
+normalization statistics is drawn at random. The weights are written by
+export_weights.py to testdata/resnet_golden.weights and the inputs and
+Tensorflow outputs to testdata/resnet_golden.txt for TestResNetGolden:
+
+    python3 alpha/resnet_golden.py --checkpoint=data/checkpoint/bot_alpha_zoo-16
+
+With --backend=reference a small network with random weights is computed by
+the pure Python layers below instead and written to testdata/resnet_reference.*
+for TestResNetReference. That checks the Go ResNet against a second reading
+of the layers but not against Tensorflow itself.
+"""
+
+import argparse
+import math
+import random
+import struct
+
+from features import FEATURE_PLANES
+
+WEIGHTS_MAGIC = b'ZOOW'
+WEIGHTS_VERSION = 1
+BATCH_NORM_EPSILON = 1e-3
+
+# Size of the reference network.
+DEPTH = 1
+FILTERS = 8
+HIDDEN = 16
+PLANES = FEATURE_PLANES
+POLICY_SIZE = 232
+INPUTS = 4
+
+
+def random_tensor(r, *shape):
+    size = 1
+    for d in shape:
+        size *= d
+    return [r.gauss(0, 0.3) for _ in range(size)]
+
+
+def random_conv(r, prefix, size, cin, cout):
+    return [
+        (prefix + 'conv/kernel', (size, size, cin, cout),
+         random_tensor(r, size, size, cin, cout)),
+        (prefix + 'conv/bias', (cout,), random_tensor(r, cout)),
+        (prefix + 'norm/gamma', (cout,), [1 + v for v in random_tensor(r, cout)]),
+        (prefix + 'norm/beta', (cout,), random_tensor(r, cout)),
+        (prefix + 'norm/moving_mean', (cout,), random_tensor(r, cout)),
+        (prefix + 'norm/moving_variance', (cout,),
+         [1 + abs(v) for v in random_tensor(r, cout)]),
+    ]
+
+
+def random_dense(r, prefix, cin, cout):
+    return [
+        (prefix + 'kernel', (cin, cout), random_tensor(r, cin, cout)),
+        (prefix + 'bias', (cout,), random_tensor(r, cout)),
+    ]
+
+
+def random_weights(r):
+    """Returns the named weight tensors in the order of export_weights.py."""
+    ts = random_conv(r, 'input_/', 3, PLANES, FILTERS)
+    for i in range(DEPTH):
+        prefix = 'res_{}/'.format(i)
+        ts += random_conv(r, prefix + 'conv1/', 3, FILTERS, FILTERS)
+        ts += random_conv(r, prefix + 'conv2/', 3, FILTERS, FILTERS)
+    ts += random_conv(r, 'value_/', 1, FILTERS, 1)
+    ts += random_dense(r, 'value_/hidden/', 6*6*1, HIDDEN)
+    ts += random_dense(r, 'value_/value_dense/', HIDDEN, 1)
+    ts += random_conv(r, 'policy_/', 1, FILTERS, 2)
+    ts += random_dense(r, 'policy_/dense/', 6*6*2, HIDDEN)
+    ts += random_dense(r, 'policy_/policy_output/', HIDDEN, POLICY_SIZE)
+    return ts
+
+
+def write_weights(path, ts):
+    with open(path, 'wb') as f:
+        f.write(WEIGHTS_MAGIC)
+        f.write(struct.pack('<II', WEIGHTS_VERSION, len(ts)))
+        for name, shape, data in ts:
+            name = name.encode()
+            f.write(struct.pack('<I', len(name)))
+            f.write(name)
+            f.write(struct.pack('<I', len(shape)))
+            f.write(struct.pack('<{}I'.format(len(shape)), *shape))
+            f.write(struct.pack('<{}f'.format(len(data)), *data))
+
+
+def float32(xs):
+    """Rounds the values to float32 as stored in the weights file."""
+    return list(struct.unpack('<{}f'.format(len(xs)), struct.pack('<{}f'.format(len(xs)), *xs)))
+
+
+def reference_conv(ws, prefix, x, n, size, same, relu):
+    """Computes a Conv2D and BatchNormalization layer on x of width n in channels last order."""
+    kernel = ws[prefix + 'conv/kernel']
+    cin, cout = kernel[0][2], kernel[0][3]
+    kernel = kernel[1]
+    bias, gamma, beta, mean, variance = (ws[prefix + name][1] for name in (
+        'conv/bias', 'norm/gamma', 'norm/beta', 'norm/moving_mean', 'norm/moving_variance'))
+    pad = size // 2 if same else 0
+    m = n + 2*pad - size + 1
+    y = []
+    for r in range(m):
+        for f in range(m):
+            for o in range(cout):
+                v = bias[o]
+                for dr in range(size):
+                    rr = r + dr - pad
+                    if rr < 0 or rr >= n:
+                        continue
+                    for df in range(size):
+                        ff = f + df - pad
+                        if ff < 0 or ff >= n:
+                            continue
+                        for i in range(cin):
+                            v += x[(rr*n + ff)*cin + i] * \
+                                kernel[((dr*size + df)*cin + i)*cout + o]
+                v = (v - mean[o]) / math.sqrt(variance[o] + BATCH_NORM_EPSILON) * gamma[o] + beta[o]
+                if relu:
+                    v = max(v, 0)
+                y.append(v)
+    return y, m
+
+
+def reference_dense(ws, prefix, x, relu):
+    (cin, cout), kernel = ws[prefix + 'kernel']
+    bias = ws[prefix + 'bias'][1]
+    y = []
+    for o in range(cout):
+        v = bias[o] + sum(x[i] * kernel[i*cout + o] for i in range(cin))
+        if relu:
+            v = max(v, 0)
+        y.append(v)
+    return y
+
+
+def reference_evaluate(ts, inputs):
+    ws = {name: (shape, float32(data)) for name, shape, data in ts}
+    outputs = []
+    for x in inputs:
+        y, n = reference_conv(ws, 'input_/', x, 8, 3, False, True)
+        for i in range(DEPTH):
+            prefix = 'res_{}/'.format(i)
+            h, _ = reference_conv(ws, prefix + 'conv1/', y, n, 3, True, True)
+            h, _ = reference_conv(ws, prefix + 'conv2/', h, n, 3, True, False)
+            y = [max(a + b, 0) for a, b in zip(h, y)]
+        v, _ = reference_conv(ws, 'value_/', y, n, 1, False, True)
+        v = reference_dense(ws, 'value_/hidden/', v, True)
+        v = math.tanh(reference_dense(ws, 'value_/value_dense/', v, False)[0])
+        p, _ = reference_conv(ws, 'policy_/', y, n, 1, False, True)
+        p = reference_dense(ws, 'policy_/dense/', p, True)
+        p = reference_dense(ws, 'policy_/policy_output/', p, False)
+        outputs.append((v, p))
+    return outputs
+
+
+def tf_evaluate(args, r, inputs):
+    """Returns the bot_alpha_zoo model and its outputs on inputs."""
+    import numpy as np
+    import tensorflow as tf
+
+    from alpha_layers import build_model
+
+    model = build_model(args.depth)
+    if args.checkpoint:
+        model.load_weights(args.checkpoint).expect_partial()
+    else:
+        for variable in model.variables:
+            shape = variable.shape.as_list()
+            data = random_tensor(r, *shape)
+            if 'moving_variance' in variable.name:
+                data = [1 + abs(v) for v in data]
+            elif 'gamma' in variable.name:
+                data = [1 + v for v in data]
+            variable.assign(np.reshape(np.asarray(data, dtype='float32'), shape))
+    values, policies = model(np.reshape(np.asarray(inputs, dtype='float32'), (-1, 8, 8, PLANES)),
+                             training=False)
+    outputs = [(float(v[0]), [float(p) for p in ps])
+               for v, ps in zip(values.numpy(), policies.numpy())]
+    return model, outputs
+
+
+def write_outputs(path, header, inputs, outputs):
+    with open(path, 'w') as f:
+        f.write('# Written by alpha/resnet_golden.py {}.\n'.format(header))
+        f.write('# Each case is the input planes in [rank][file][plane] order, the value and the policy.\n')
+        for x, (v, p) in zip(inputs, outputs):
+            f.write('input {}\n'.format(''.join(str(b) for b in x)))
+            f.write('value {!r}\n'.format(v))
+            f.write('policy {}\n'.format(' '.join(repr(q) for q in p)))
+
+
+def main():
+    parser = argparse.ArgumentParser()
+    parser.add_argument('--backend', choices=('tf', 'reference'), default='tf')
+    parser.add_argument('--checkpoint', default='',
+                        help='bot_alpha_zoo checkpoint written by model.py (tf backend)')
+    parser.add_argument('--depth', type=int, default=16, help='residual layers (tf backend)')
+    parser.add_argument('--testdata', default='testdata')
+    parser.add_argument('--seed', type=int, default=1)
+    args = parser.parse_args()
+
+    r = random.Random(args.seed)
+    if args.backend == 'tf':
+        import tensorflow as tf
+
+        from export_weights import export_weights
+
+        inputs = [[1 if r.random() < 0.15 else 0 for _ in range(8*8*PLANES)] for _ in range(INPUTS)]
+        model, outputs = tf_evaluate(args, r, inputs)
+        source = args.checkpoint or 'random weights with --seed={}'.format(args.seed)
+        export_weights(model, '{}/resnet_golden.weights'.format(args.testdata))
+        write_outputs('{}/resnet_golden.txt'.format(args.testdata),
+                      '--backend=tf from {} ({}) with Tensorflow {}'.format(
+                          model.name, source, tf.__version__),
+                      inputs, outputs)
+        return
+
+    ts = random_weights(r)
+    inputs = [[1 if r.random() < 0.15 else 0 for _ in range(8*8*PLANES)] for _ in range(INPUTS)]
+    outputs = reference_evaluate(ts, inputs)
+    write_weights('{}/resnet_reference.weights'.format(args.testdata), ts)
+    write_outputs('{}/resnet_reference.txt'.format(args.testdata),
+                  '--backend=reference --seed={}'.format(args.seed), inputs, outputs)
+
+
+if __name__ == '__main__':
+    main()
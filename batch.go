@@ -0,0 +1,124 @@
+package zoo
+
+import (
+	"sync"
+	"time"
+)
+
+// BatchEvaluator implements ModelInterface by collecting positions from
+// concurrent callers and evaluating them with the underlying model in batches.
+// A batch is flushed when it reaches the batch size or when the timeout elapses
+// after the first position was added to it.
+type BatchEvaluator struct {
+	model   ModelInterface
+	size    int
+	timeout time.Duration
+
+	m       sync.Mutex // guards pending.
+	pending *evalBatch // batch being collected or nil.
+}
+
+// evalBatch is a batch of positions waiting for evaluation.
+type evalBatch struct {
+	ps       []*Pos
+	values   []float32
+	policies [][]float32
+	timer    *time.Timer
+	done     chan struct{} // closed after evaluation.
+}
+
+// NewBatchEvaluator creates a BatchEvaluator over model with the given batch size and timeout.
+func NewBatchEvaluator(model ModelInterface, size int, timeout time.Duration) *BatchEvaluator {
+	if size < 1 {
+		size = 1
+	}
+	return &BatchEvaluator{
+		model:   model,
+		size:    size,
+		timeout: timeout,
+	}
+}
+
+// add adds p to the pending batch and returns the batch and index of p.
+// The batch is evaluated by the caller that fills it or by the timer.
+func (b *BatchEvaluator) add(p *Pos) (*evalBatch, int) {
+	b.m.Lock()
+	batch := b.pending
+	if batch == nil {
+		batch = &evalBatch{
+			ps:   make([]*Pos, 0, b.size),
+			done: make(chan struct{}),
+		}
+		b.pending = batch
+		if b.size > 1 {
+			batch.timer = time.AfterFunc(b.timeout, func() { b.flush(batch) })
+		}
+	}
+	i := len(batch.ps)
+	batch.ps = append(batch.ps, p)
+	full := len(batch.ps) >= b.size
+	if full {
+		b.pending = nil
+	}
+	b.m.Unlock()
+
+	if full {
+		if batch.timer != nil {
+			batch.timer.Stop()
+		}
+		b.run(batch)
+	}
+	return batch, i
+}
+
+// flush evaluates batch if it is still pending.
+func (b *BatchEvaluator) flush(batch *evalBatch) {
+	b.m.Lock()
+	if b.pending != batch {
+		// Batch was filled in the meantime.
+		b.m.Unlock()
+		return
+	}
+	b.pending = nil
+	b.m.Unlock()
+	b.run(batch)
+}
+
+func (b *BatchEvaluator) run(batch *evalBatch) {
+	batch.values, batch.policies = b.model.EvaluateBatch(batch.ps)
+	close(batch.done)
+}
+
+// EvaluateBatch adds the positions to the pending batches and waits for their evaluation.
+func (b *BatchEvaluator) EvaluateBatch(ps []*Pos) (values []float32, policies [][]float32) {
+	batches := make([]*evalBatch, len(ps))
+	idx := make([]int, len(ps))
+	for i, p := range ps {
+		batches[i], idx[i] = b.add(p)
+	}
+	values = make([]float32, len(ps))
+	policies = make([][]float32, len(ps))
+	for i, batch := range batches {
+		<-batch.done
+		values[i] = batch.values[idx[i]]
+		policies[i] = batch.policies[idx[i]]
+	}
+	return values, policies
+}
+
+// SetSeed sets the seed of the underlying model.
+func (b *BatchEvaluator) SetSeed(seed int64) {
+	b.model.SetSeed(seed)
+}
+
+// Close flushes the pending batch.
+// The underlying model is not closed.
+func (b *BatchEvaluator) Close() error {
+	b.m.Lock()
+	batch := b.pending
+	b.m.Unlock()
+	if batch != nil {
+		b.flush(batch)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package zoo
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingModel records the size of every batch it evaluates.
+type countingModel struct {
+	m       sync.Mutex
+	batches []int
+}
+
+func (m *countingModel) EvaluateBatch(ps []*Pos) (values []float32, policies [][]float32) {
+	m.m.Lock()
+	m.batches = append(m.batches, len(ps))
+	m.m.Unlock()
+	values = make([]float32, len(ps))
+	policies = make([][]float32, len(ps))
+	for i, p := range ps {
+		values[i] = float32(p.moveNum)
+		policies[i] = []float32{float32(p.moveNum)}
+	}
+	return values, policies
+}
+
+func (m *countingModel) SetSeed(int64) {}
+
+func (m *countingModel) Close() error { return nil }
+
+func TestBatchEvaluator(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		size        int
+		timeout     time.Duration
+		callers     int
+		wantBatches []int
+	}{{
+		name:        "flush on size",
+		size:        4,
+		timeout:     time.Hour,
+		callers:     8,
+		wantBatches: []int{4, 4},
+	}, {
+		name:        "flush on timeout",
+		size:        8,
+		timeout:     time.Millisecond,
+		callers:     3,
+		wantBatches: []int{3},
+	}, {
+		name:        "unbatched",
+		size:        1,
+		timeout:     time.Hour,
+		callers:     2,
+		wantBatches: []int{1, 1},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			model := &countingModel{}
+			b := NewBatchEvaluator(model, tc.size, tc.timeout)
+			var wg sync.WaitGroup
+			for i := 0; i < tc.callers; i++ {
+				p := &Pos{moveNum: i + 1}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					values, policies := b.EvaluateBatch([]*Pos{p})
+					if got, want := values[0], float32(p.moveNum); got != want {
+						t.Errorf("EvaluateBatch(): got value %v, want %v", got, want)
+					}
+					if got, want := policies[0][0], float32(p.moveNum); got != want {
+						t.Errorf("EvaluateBatch(): got policy %v, want %v", got, want)
+					}
+				}()
+			}
+			wg.Wait()
+			if len(model.batches) != len(tc.wantBatches) {
+				t.Fatalf("EvaluateBatch(): got batches %v, want %v", model.batches, tc.wantBatches)
+			}
+			for i := range model.batches {
+				if model.batches[i] != tc.wantBatches[i] {
+					t.Fatalf("EvaluateBatch(): got batches %v, want %v", model.batches, tc.wantBatches)
+				}
+			}
+		})
+	}
+}
+
+func TestDummyModelEvaluateBatch(t *testing.T) {
+	ps := []*Pos{{}, {}, {}}
+	values, policies := NewDummyModel().EvaluateBatch(ps)
+	if len(values) != len(ps) || len(policies) != len(ps) {
+		t.Fatalf("EvaluateBatch(): got %d values and %d policies, want %d", len(values), len(policies), len(ps))
+	}
+	for i, policy := range policies {
+		if len(policy) != modelOutputPolicySize {
+			t.Errorf("EvaluateBatch(): got policy %d of size %d, want %d", i, len(policy), modelOutputPolicySize)
+		}
+		if values[i] < -1 || values[i] > 1 {
+			t.Errorf("EvaluateBatch(): got value %d = %v, want in [-1, 1]", i, values[i])
+		}
+	}
+}
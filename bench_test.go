@@ -1,23 +1,64 @@
 package zoo
 
 import (
+	"fmt"
 	"testing"
 )
 
 func BenchmarkOpening(b *testing.B) {
-	engine, err := NewEngine(&EngineSettings{
-		Seed: 1337,
-	}, &AEISettings{
-		LogProtocolTraffic: true,
-	})
-	if err != nil {
-		b.Fatal(err)
-	}
-	if err := engine.ExecuteCommand("setposition g [rrrrrrrrhdcemcdh                                HDCMECDHRRRRRRRR]"); err != nil {
-		b.Fatal(err)
+	for _, goroutines := range []uint{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("goroutines=%d", goroutines), func(b *testing.B) {
+			engine, err := NewEngine(&EngineSettings{
+				Seed:        1337,
+				Concurrency: goroutines,
+			}, &AEISettings{
+				LogProtocolTraffic: true,
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+			if err := engine.ExecuteCommand("setposition g [rrrrrrrrhdcemcdh                                HDCMECDHRRRRRRRR]"); err != nil {
+				b.Fatal(err)
+			}
+
+			for n := 0; n < b.N; n++ {
+				engine.tree.Reset()
+				engine.GoWait()
+			}
+		})
 	}
+}
 
-	for n := 0; n < b.N; n++ {
-		engine.GoWait()
+// BenchmarkMidgame measures playouts/s in midgame positions, where TreeNode.expand runs
+// Pos.Goal at the first node of each turn, and the goal searches run for one position:
+// Pos.Goal and goalThreat for the goal threat plane.
+func BenchmarkMidgame(b *testing.B) {
+	const playouts = 400
+	for _, name := range []string{"game_489557.txt", "juhnke_11.txt", "test_position_1.txt"} {
+		p := readTestPosition(b, name)
+		b.Run(name+"/search", func(b *testing.B) {
+			engine, err := NewEngine(&EngineSettings{Seed: 1337}, &AEISettings{})
+			if err != nil {
+				b.Fatal(err)
+			}
+			if err := engine.ExecuteSetOption(fmt.Sprintf("name playouts value %d", playouts)); err != nil {
+				b.Fatal(err)
+			}
+			engine.timeInfo = nil
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				engine.Pos = p.Clone()
+				engine.tree.Reset()
+				engine.GoWait()
+			}
+			b.ReportMetric(float64(b.N*playouts)/b.Elapsed().Seconds(), "playouts/s")
+		})
+		b.Run(name+"/goal", func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				q := p.Clone()
+				q.Goal()
+				goalThreat(q)
+			}
+		})
 	}
 }
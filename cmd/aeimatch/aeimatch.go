@@ -0,0 +1,285 @@
+// Command aeimatch plays a match between two engines started as subprocesses over AEI.
+// Engines alternate colors each game and the time control is enforced by the controller.
+// For example, to play bot_alpha_zoo against itself with different options:
+//
+//	aeimatch -bot1 "bot_alpha_zoo -O playouts=100" -bot2 "bot_alpha_zoo -O playouts=400"
+//
+// The match is written as a snappy compressed Match proto along with a move list for each game.
+//
+// Games which reach -max_turns or the turn limit of the time control end without a winner
+// and count as draws. The score which decides such games under the Arimaa rules is not used.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	zoo "github.com/ajzaff/bot_zoo"
+	zoopb "github.com/ajzaff/bot_zoo/proto"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+)
+
+var (
+	bot1        = flag.String("bot1", "", "Command line of the first engine")
+	bot2        = flag.String("bot2", "", "Command line of the second engine")
+	name1       = flag.String("name1", "bot1", "Name of the first engine")
+	name2       = flag.String("name2", "bot2", "Name of the second engine")
+	games       = flag.Int("games", 2, "Number of games to play")
+	timeControl = flag.String("tc", "3s/30s/100/60s/10m", "Time control in arimaa.com notation or empty for no time limit")
+	stopMargin  = flag.Duration("stop_margin", time.Second, "Time left on the clock when stop is sent to the engine")
+	position    = flag.String("position", "", "Start games from this position in short or full notation instead of setup")
+	maxTurns    = flag.Int("max_turns", 600, "Number of turns after which a game ends in a draw or 0 for no limit")
+	outputDir   = flag.String("output_dir", filepath.Join("data", "matches"), "Directory to write the match and move lists to")
+	matchID     = flag.String("id", "", "Match ID used in file names; defaults to the start time")
+	verbose     = flag.Bool("v", false, "Log protocol traffic and engine stderr")
+)
+
+// game is a finished game of the match.
+type game struct {
+	gold   int          // index of the gold player.
+	value  zoo.Value    // result for gold or 0 for a draw.
+	reason string       // reason the game ended.
+	moves  zoo.MoveList // moves played from the start position.
+}
+
+// winner returns the index of the winning player or -1.
+func (g *game) winner() int {
+	switch {
+	case g.value > 0:
+		return g.gold
+	case g.value < 0:
+		return 1 - g.gold
+	default:
+		return -1
+	}
+}
+
+func main() {
+	log.SetFlags(0)
+	flag.Parse()
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run plays the match given by the flags.
+// The engines are closed before run returns.
+func run() error {
+	if *bot1 == "" || *bot2 == "" {
+		return fmt.Errorf("aeimatch: -bot1 and -bot2 are required")
+	}
+	var tc zoo.TimeControl
+	if *timeControl != "" {
+		var err error
+		if tc, err = zoo.ParseTimeControl(*timeControl); err != nil {
+			return err
+		}
+		// The AEI time options are whole seconds.
+		for _, d := range []time.Duration{tc.Move, tc.Reserve, tc.MaxReserve, tc.GameTotal, tc.MaxTurn} {
+			if d%time.Second != 0 {
+				return fmt.Errorf("aeimatch: time control %s: times must be whole seconds", tc)
+			}
+		}
+	}
+	var start *zoo.Pos
+	if *position != "" {
+		var err error
+		if start, err = zoo.ParsePosition(*position); err != nil {
+			return err
+		}
+	}
+	id := *matchID
+	if id == "" {
+		id = time.Now().Format("20060102_150405")
+	}
+	if err := os.MkdirAll(*outputDir, 0755); err != nil {
+		return err
+	}
+
+	names := [2]string{*name1, *name2}
+	var engines [2]*engine
+	for i, cmdline := range []string{*bot1, *bot2} {
+		e, err := startEngine(names[i], cmdline, tc)
+		if err != nil {
+			return err
+		}
+		defer e.Close()
+		engines[i] = e
+	}
+
+	match := &zoopb.Match{
+		Id:      id,
+		Players: names[:],
+		Results: &zoopb.Match_Result{Wins: make([]uint32, 2)},
+	}
+	draws := 0
+	for n := 1; n <= *games; n++ {
+		gold := (n - 1) % 2
+		g, err := playGame([2]*engine{engines[gold], engines[1-gold]}, tc, start)
+		if err != nil {
+			return err
+		}
+		g.gold = gold
+		if w := g.winner(); w >= 0 {
+			match.Results.Wins[w]++
+			log.Printf("game %d: %s (g) vs %s (s): %s wins by %s", n, names[gold], names[1-gold], names[w], g.reason)
+		} else {
+			draws++
+			log.Printf("game %d: %s (g) vs %s (s): draw by %s", n, names[gold], names[1-gold], g.reason)
+		}
+
+		moves := moveListString(start, g.moves)
+		match.Games = append(match.Games, &zoopb.Match_Game{
+			GoldPlayer:   uint32(gold),
+			SilverPlayer: uint32(1 - gold),
+			Pgn: &zoopb.PGN{
+				GoldPlayer:   names[gold],
+				SilverPlayer: names[1-gold],
+				Pgn:          moves,
+				Result:       int32(g.value),
+			},
+		})
+		path := filepath.Join(*outputDir, fmt.Sprintf("%s_%d.txt", id, n))
+		if err := ioutil.WriteFile(path, []byte(moves), 0644); err != nil {
+			return err
+		}
+		// The match is rewritten after every game so that results survive an interrupted match.
+		if err := writeMatch(filepath.Join(*outputDir, id+".pb.snappy"), match); err != nil {
+			return err
+		}
+	}
+	log.Printf("%s %d - %d %s (%d draws)", names[0], match.Results.Wins[0], match.Results.Wins[1], names[1], draws)
+	return nil
+}
+
+// playGame plays a game between the gold and silver engines from start or from setup if start is nil.
+// An error is returned if an engine stops responding to AEI commands.
+func playGame(engines [2]*engine, tc zoo.TimeControl, start *zoo.Pos) (*game, error) {
+	p := zoo.NewEmptyPosition()
+	if start != nil {
+		p = start.Clone()
+	}
+	for _, e := range engines {
+		if err := e.send("newgame"); err != nil {
+			return nil, err
+		}
+		if start != nil {
+			if err := e.send("setposition %s", start.FullString()); err != nil {
+				return nil, err
+			}
+		}
+		if err := e.ready(); err != nil {
+			return nil, err
+		}
+	}
+
+	g := &game{}
+	// lose ends the game with a loss for side c.
+	lose := func(c zoo.Color, reason string) {
+		g.value, g.reason = zoo.Win, reason
+		if c == zoo.Gold {
+			g.value = zoo.Loss
+		}
+	}
+	t := tc.NewTimeInfo(time.Now())
+	for {
+		c := p.Side()
+		if r := p.Terminal(); r.Terminal() {
+			if g.reason = r.Reason.String(); c == zoo.Gold {
+				g.value = r.Value
+			} else {
+				g.value = -r.Value
+			}
+			return g, nil
+		}
+		if *maxTurns > 0 && len(g.moves) >= *maxTurns || tc.Turns > 0 && t.Turns >= tc.Turns {
+			g.reason = "turn limit"
+			return g, nil
+		}
+
+		e := engines[c]
+		if err := e.send("setoption name greserve value %d", int(t.Reserve[zoo.Gold]/time.Second)); err != nil {
+			return nil, err
+		}
+		if err := e.send("setoption name sreserve value %d", int(t.Reserve[zoo.Silver]/time.Second)); err != nil {
+			return nil, err
+		}
+		limit := tc.GameTimeRemaining(t, c)
+		s, err := e.bestMove(limit-*stopMargin, limit)
+		if errors.Is(err, errTimeout) {
+			lose(c, "time")
+			if err := e.ready(); err != nil {
+				return nil, err
+			}
+			return g, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		tc.EndTurn(t, c, time.Now())
+
+		m, err := zoo.ParseMove(s)
+		if err == nil {
+			m, err = p.NormalizeMove(m)
+		}
+		if err != nil {
+			log.Printf("%s: %v", e.name, err)
+			lose(c, "illegal move")
+			return g, nil
+		}
+		p.Move(m)
+		g.moves = append(g.moves, m)
+		for _, e := range engines {
+			if err := e.send("makemove %s", m); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// moveListString returns the moves of a game in move list notation.
+// Games from a start position begin with the position in full notation,
+// which keeps the side to move, the steps of the turn and the push state,
+// and number the moves from its turn.
+func moveListString(start *zoo.Pos, moves zoo.MoveList) string {
+	if start == nil {
+		return moves.String()
+	}
+	var sb strings.Builder
+	sb.WriteString(start.FullString())
+	sb.WriteByte('\n')
+	n, c := start.MoveNum(), start.Side()
+	for _, m := range moves {
+		fmt.Fprintf(&sb, "%d%c %s\n", n, c.Byte(), m)
+		if c = c.Opposite(); c == zoo.Gold {
+			n++
+		}
+	}
+	return sb.String()
+}
+
+// writeMatch writes the match to a snappy compressed file at path.
+func writeMatch(path string, match *zoopb.Match) error {
+	payload, err := proto.Marshal(match)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	sw := snappy.NewBufferedWriter(&buf)
+	if _, err := sw.Write(payload); err != nil {
+		return err
+	}
+	if err := sw.Close(); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	zoo "github.com/ajzaff/bot_zoo"
+	zoopb "github.com/ajzaff/bot_zoo/proto"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+)
+
+// The test binary runs as a stub engine when this variable is set.
+// The mode is the first argument: "first" plays the first generated move
+// and "illegal" plays a move which is never legal during setup.
+const stubEngineEnv = "AEIMATCH_STUB_ENGINE"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(stubEngineEnv) != "" {
+		stubEngine(os.Args[1])
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// stubEngine answers AEI commands on stdin until quit.
+func stubEngine(mode string) {
+	p := zoo.NewEmptyPosition()
+	sc := bufio.NewScanner(os.Stdin)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "aei":
+			fmt.Println("aeiok")
+		case "isready":
+			fmt.Println("readyok")
+		case "newgame":
+			p = zoo.NewEmptyPosition()
+		case "makemove":
+			m, err := zoo.ParseMove(strings.Join(fields[1:], " "))
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return
+			}
+			p.Move(m)
+		case "go":
+			fmt.Println("bestmove", stubMove(p, mode))
+		case "quit":
+			return
+		}
+	}
+}
+
+func stubMove(p *zoo.Pos, mode string) string {
+	switch {
+	case mode == "illegal":
+		return "Ra1n"
+	case p.MoveNum() > 1:
+		return p.GenerateMoves()[0].String()
+	case p.Side() == zoo.Gold:
+		return "Ra1 Rb1 Rc1 Rd1 Re1 Rf1 Rg1 Rh1 Ha2 Db2 Cc2 Md2 Ee2 Cf2 Dg2 Hh2"
+	default:
+		return "ra8 rb8 rc8 rd8 re8 rf8 rg8 rh8 ha7 db7 cc7 ed7 me7 cf7 dg7 hh7"
+	}
+}
+
+// runStubMatch runs a match of two games between stub engines with the given modes
+// and returns the written match.
+func runStubMatch(t *testing.T, mode1, mode2 string) *zoopb.Match {
+	t.Helper()
+	t.Setenv(stubEngineEnv, "1")
+	dir := t.TempDir()
+	for flag, value := range map[*string]string{
+		bot1:        os.Args[0] + " " + mode1,
+		bot2:        os.Args[0] + " " + mode2,
+		timeControl: "",
+		outputDir:   dir,
+		matchID:     "test",
+	} {
+		old := *flag
+		*flag = value
+		t.Cleanup(func() { *flag = old })
+	}
+	oldTurns := *maxTurns
+	*maxTurns = 6
+	t.Cleanup(func() { *maxTurns = oldTurns })
+
+	if err := run(); err != nil {
+		t.Fatalf("run(): %v", err)
+	}
+	bs, err := ioutil.ReadAll(snappy.NewReader(openFile(t, filepath.Join(dir, "test.pb.snappy"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	match := &zoopb.Match{}
+	if err := proto.Unmarshal(bs, match); err != nil {
+		t.Fatal(err)
+	}
+	if len(match.Games) != *games {
+		t.Fatalf("run(): got %d games, want %d", len(match.Games), *games)
+	}
+	return match
+}
+
+func openFile(t *testing.T, path string) *os.File {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func TestRunTurnLimit(t *testing.T) {
+	match := runStubMatch(t, "first", "first")
+	if got := match.Results.Wins; got[0] != 0 || got[1] != 0 {
+		t.Errorf("run(): got wins %v, want [0 0]", got)
+	}
+	for i, g := range match.Games {
+		if g.Pgn.Result != 0 {
+			t.Errorf("run(): got result %d for game %d, want a draw", g.Pgn.Result, i)
+		}
+		l, err := zoo.ParseMoveList(g.Pgn.Pgn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(l) != *maxTurns {
+			t.Errorf("run(): got %d turns in game %d, want %d", len(l), i, *maxTurns)
+		}
+	}
+}
+
+func TestRunIllegalMove(t *testing.T) {
+	match := runStubMatch(t, "first", "illegal")
+	// bot1 wins both games since bot2 never plays a legal setup.
+	if got := match.Results.Wins; got[0] != 2 || got[1] != 0 {
+		t.Errorf("run(): got wins %v, want [2 0]", got)
+	}
+}
+
+func TestRunEngineExits(t *testing.T) {
+	old1, old2, oldDir := *bot1, *bot2, *outputDir
+	defer func() { *bot1, *bot2, *outputDir = old1, old2, oldDir }()
+	*bot1, *bot2, *outputDir = "true", "true", t.TempDir()
+	if err := run(); err == nil {
+		t.Errorf("run(): got nil error for an engine which exits, want error")
+	}
+}
+
+func TestRunFractionalTime(t *testing.T) {
+	old1, old2, oldTC := *bot1, *bot2, *timeControl
+	defer func() { *bot1, *bot2, *timeControl = old1, old2, oldTC }()
+	*bot1, *bot2, *timeControl = "true", "true", "0.5s/30s"
+	if err := run(); err == nil || !strings.Contains(err.Error(), "whole seconds") {
+		t.Errorf("run(): got error %v, want whole seconds error", err)
+	}
+}
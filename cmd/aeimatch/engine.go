@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	zoo "github.com/ajzaff/bot_zoo"
+)
+
+// handshakeTimeout bounds the replies to aei and isready.
+const handshakeTimeout = 30 * time.Second
+
+var errTimeout = errors.New("timed out")
+
+// engine is an engine subprocess controlled over AEI.
+type engine struct {
+	name  string
+	cmd   *exec.Cmd
+	lines chan string // lines output by the engine; closed when it exits.
+
+	mu sync.Mutex // guards in.
+	in io.WriteCloser
+}
+
+// startEngine starts the engine given by the command line and completes the AEI handshake.
+// Arguments are separated by spaces. The time control tc is sent as AEI options.
+func startEngine(name, cmdline string, tc zoo.TimeControl) (*engine, error) {
+	args := strings.Fields(cmdline)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%s: empty command line", name)
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	if *verbose {
+		cmd.Stderr = os.Stderr
+	}
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	e := &engine{
+		name:  name,
+		cmd:   cmd,
+		in:    in,
+		lines: make(chan string, 100),
+	}
+	go func() {
+		defer close(e.lines)
+		sc := bufio.NewScanner(out)
+		for sc.Scan() {
+			text := strings.TrimSpace(sc.Text())
+			if *verbose {
+				log.Printf("%s > %s", e.name, text)
+			}
+			e.lines <- text
+		}
+	}()
+
+	if err := e.send("aei"); err != nil {
+		return nil, err
+	}
+	if _, err := e.expect("aeiok", handshakeTimeout); err != nil {
+		e.Close()
+		return nil, err
+	}
+	for _, opt := range []struct {
+		name  string
+		value int
+	}{
+		{"tcmove", int(tc.Move / time.Second)},
+		{"tcreserve", int(tc.Reserve / time.Second)},
+		{"tcpercent", tc.MoveReservePercent},
+		{"tcmax", int(tc.MaxReserve / time.Second)},
+		{"tctotal", int(tc.GameTotal / time.Second)},
+		{"tcturns", tc.Turns},
+		{"tcturntime", int(tc.MaxTurn / time.Second)},
+	} {
+		if err := e.send("setoption name %s value %d", opt.name, opt.value); err != nil {
+			return nil, err
+		}
+	}
+	if err := e.ready(); err != nil {
+		e.Close()
+		return nil, err
+	}
+	return e, nil
+}
+
+// send sends the formatted AEI command to the engine.
+func (e *engine) send(format string, a ...interface{}) error {
+	s := fmt.Sprintf(format, a...)
+	if *verbose {
+		log.Printf("%s < %s", e.name, s)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, err := fmt.Fprintln(e.in, s); err != nil {
+		return fmt.Errorf("%s: %v", e.name, err)
+	}
+	return nil
+}
+
+// expect discards lines until one starting with the given message and returns the rest of it.
+func (e *engine) expect(message string, timeout time.Duration) (string, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case line, ok := <-e.lines:
+			if !ok {
+				return "", fmt.Errorf("%s: exited waiting for %s", e.name, message)
+			}
+			if line == message || strings.HasPrefix(line, message+" ") {
+				return strings.TrimSpace(line[len(message):]), nil
+			}
+		case <-timer.C:
+			return "", fmt.Errorf("%s: %s: %w", e.name, message, errTimeout)
+		}
+	}
+}
+
+// ready waits for the engine to process all commands sent so far.
+// Stale output such as a late bestmove is discarded.
+func (e *engine) ready() error {
+	if err := e.send("isready"); err != nil {
+		return err
+	}
+	_, err := e.expect("readyok", handshakeTimeout)
+	return err
+}
+
+// bestMove sends go and returns the move played by the engine.
+// stop is sent after stopAfter and errTimeout is returned after limit.
+func (e *engine) bestMove(stopAfter, limit time.Duration) (string, error) {
+	if err := e.send("go"); err != nil {
+		return "", err
+	}
+	stop := time.AfterFunc(stopAfter, func() { e.send("stop") })
+	defer stop.Stop()
+	return e.expect("bestmove", limit)
+}
+
+// Close asks the engine to quit and waits for it to exit.
+// The engine is killed if it does not exit in time.
+func (e *engine) Close() error {
+	e.send("quit")
+	e.mu.Lock()
+	e.in.Close()
+	e.mu.Unlock()
+	timer := time.NewTimer(handshakeTimeout)
+	defer timer.Stop()
+	for {
+		select {
+		case _, ok := <-e.lines:
+			if !ok {
+				return e.cmd.Wait()
+			}
+		case <-timer.C:
+			e.cmd.Process.Kill()
+			timer.Reset(handshakeTimeout)
+		}
+	}
+}
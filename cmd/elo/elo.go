@@ -0,0 +1,134 @@
+// Command elo reports the Elo difference between two players with a sequential probability
+// ratio test from match results. Matches are read from Match proto files (.pb or .pb.snappy)
+// and from the output of aeimatch. For example:
+//
+//	elo -player bot2 -elo0 0 -elo1 10 data/matches/*.pb.snappy
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+
+	zoo "github.com/ajzaff/bot_zoo"
+	zoopb "github.com/ajzaff/bot_zoo/proto"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+)
+
+var (
+	player = flag.String("player", "", "Player to report results for; defaults to the first player of the first match")
+	elo0   = flag.Float64("elo0", 0, "Elo difference of the null hypothesis H0")
+	elo1   = flag.Float64("elo1", 10, "Elo difference of the alternative hypothesis H1")
+	alpha  = flag.Float64("alpha", 0.05, "Probability of accepting H1 when H0 holds")
+	beta   = flag.Float64("beta", 0.05, "Probability of accepting H0 when H1 holds")
+)
+
+func main() {
+	log.SetFlags(0)
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		log.Fatal("elo: no match files given")
+	}
+	var (
+		score    zoo.MatchScore
+		opponent string
+	)
+	for _, path := range flag.Args() {
+		m, err := readMatch(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *player == "" && len(m.Players) > 0 {
+			*player = m.Players[0]
+		}
+		if err := score.AddMatch(m, *player); err != nil {
+			log.Fatalf("%s: %v", path, err)
+		}
+		for _, name := range m.Players {
+			if name != *player {
+				opponent = name
+			}
+		}
+	}
+
+	fmt.Printf("%s vs %s: %d wins, %d losses, %d draws in %d games\n",
+		*player, opponent, score.Wins, score.Losses, score.Draws, score.Games())
+	diff, margin := score.Elo()
+	fmt.Printf("Elo: %+.1f +/- %.1f (95%%)\n", diff, margin)
+	t := zoo.SPRT{Elo0: *elo0, Elo1: *elo1, Alpha: *alpha, Beta: *beta}
+	llr, r := t.Test(score)
+	lower, upper := t.Bounds()
+	fmt.Printf("SPRT elo0=%g elo1=%g alpha=%g beta=%g: LLR %.2f [%.2f, %.2f] %s\n",
+		t.Elo0, t.Elo1, t.Alpha, t.Beta, llr, lower, upper, r)
+}
+
+// readMatch reads the match at path. Files ending in .pb and .pb.snappy are read as Match
+// protos and other files as aeimatch output.
+func readMatch(path string) (*zoopb.Match, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	switch {
+	case strings.HasSuffix(path, ".pb.snappy"):
+		r = snappy.NewReader(f)
+	case strings.HasSuffix(path, ".pb"):
+	default:
+		return parseMatchOutput(f)
+	}
+	bs, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	m := &zoopb.Match{}
+	if err := proto.Unmarshal(bs, m); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return m, nil
+}
+
+var gamePattern = regexp.MustCompile(`^game \d+: (\S+) \(g\) vs (\S+) \(s\): (?:(\S+) wins|no winner) by `)
+
+// parseMatchOutput reads the games logged by aeimatch from r. Other lines are ignored.
+func parseMatchOutput(r io.Reader) (*zoopb.Match, error) {
+	m := &zoopb.Match{}
+	index := func(name string) uint32 {
+		for i, p := range m.Players {
+			if p == name {
+				return uint32(i)
+			}
+		}
+		m.Players = append(m.Players, name)
+		return uint32(len(m.Players) - 1)
+	}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		match := gamePattern.FindStringSubmatch(sc.Text())
+		if match == nil {
+			continue
+		}
+		g := &zoopb.Match_Game{
+			GoldPlayer:   index(match[1]),
+			SilverPlayer: index(match[2]),
+			Pgn:          &zoopb.PGN{GoldPlayer: match[1], SilverPlayer: match[2]},
+		}
+		switch match[3] {
+		case match[1]:
+			g.Pgn.Result = 1
+		case match[2]:
+			g.Pgn.Result = -1
+		}
+		m.Games = append(m.Games, g)
+	}
+	return m, sc.Err()
+}
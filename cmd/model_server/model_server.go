@@ -0,0 +1,67 @@
+// Command model_server serves one loaded model to several engines over a Unix socket.
+// Engines connect with -model_server and positions from all engines are evaluated in shared batches.
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	zoo "github.com/ajzaff/bot_zoo"
+)
+
+var (
+	socketPath        = flag.String("socket", "/tmp/bot_zoo_model.sock", "Unix socket to listen on")
+	useSavedModel     = flag.Bool("use_saved_model", false, "Use the Tensorflow model at saved_model_path")
+	savedModelPath    = flag.String("saved_model_path", "", "Path to GraphDef binary protocol buffer")
+	resNetWeightsPath = flag.String("resnet_weights_path", "", "Path to weights for the pure Go ResNet")
+	batchSize         = flag.Int("batch_size", 64, "Maximum number of positions to evaluate together")
+	batchTimeout      = flag.Duration("batch_timeout", time.Millisecond, "Maximum time to wait for a batch to fill")
+)
+
+func main() {
+	log.SetFlags(0)
+	flag.Parse()
+
+	var model zoo.ModelInterface
+	switch {
+	case *useSavedModel:
+		m, err := zoo.NewModel(*savedModelPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		model = m
+	case *resNetWeightsPath != "":
+		m, err := zoo.LoadResNet(*resNetWeightsPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		model = m
+	default:
+		log.Println("No model configured: serving the dummy model")
+		model = zoo.NewDummyModel()
+	}
+	defer model.Close()
+
+	l, err := net.Listen("unix", *socketPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	s := zoo.NewModelServer(model, *batchSize, *batchTimeout)
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		s.Close()
+	}()
+
+	log.Printf("Serving model on %s", *socketPath)
+	if err := s.Serve(l); err != nil {
+		log.Fatal(err)
+	}
+}
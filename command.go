@@ -3,10 +3,8 @@ package zoo
 import (
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"math/rand"
-	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -83,11 +81,14 @@ func init() {
 		return errAEIQuit
 	})
 	RegisterAEIHandler("setposition", func(e *Engine, args string) error {
-		p, err := ParseShortPosition(args)
+		p, err := ParsePosition(args)
 		if err != nil {
 			return err
 		}
 		e.Pos = p
+		if e.timeInfo != nil {
+			e.timeControl.resetTurn(e.timeInfo, p.Side(), e.now())
+		}
 		return nil
 	})
 	RegisterAEIHandler("setoption", func(e *Engine, args string) error {
@@ -99,7 +100,11 @@ func init() {
 		if err != nil {
 			return err
 		}
-		e.Move(move)
+		if err := e.ValidateMove(move); err != nil {
+			return err
+		}
+		e.endTurn()
+		e.MakeMove(move)
 		return nil
 	})
 	RegisterAEIHandler("go", func(e *Engine, args string) error {
@@ -208,8 +213,8 @@ func init() {
 		if err != nil {
 			return err
 		}
-		if !e.Legal(step) {
-			return fmt.Errorf("illegal step")
+		if err := e.checkStep(step); err != nil {
+			return fmt.Errorf("illegal step: %v", err)
 		}
 		e.Step(step)
 		return nil
@@ -231,7 +236,61 @@ func init() {
 		return nil
 	}))
 	RegisterAEIHandler("eval", extendedHandler(func(e *Engine, args string) error {
-		e.Logf("%f", e.Terminal())
+		r := e.Terminal()
+		e.Logf("%f %s", r.Value, r.Reason)
+		return nil
+	}))
+	RegisterAEIHandler("goal", extendedHandler(func(e *Engine, args string) error {
+		m, ok := e.Goal()
+		if !ok {
+			e.Logf("no goal")
+			return nil
+		}
+		e.Logf("%s", m)
+		return nil
+	}))
+	RegisterAEIHandler("perft", extendedHandler(func(e *Engine, args string) error {
+		// perft [divide] [steps|turns] N
+		var divide, steps bool
+		fields := strings.Fields(args)
+		if len(fields) == 0 {
+			return fmt.Errorf("missing depth")
+		}
+		for _, f := range fields[:len(fields)-1] {
+			switch f {
+			case "divide":
+				divide = true
+			case "steps":
+				steps = true
+			case "turns":
+				steps = false
+			default:
+				return fmt.Errorf("unrecognized perft argument: %s", f)
+			}
+		}
+		depth, err := strconv.Atoi(fields[len(fields)-1])
+		if err != nil || depth < 0 {
+			return fmt.Errorf("bad depth: %s", fields[len(fields)-1])
+		}
+		if !divide {
+			e.Logf("steps %d", e.PerftSteps(depth))
+			e.Logf("turns %d", e.PerftTurns(depth))
+			return nil
+		}
+		var total uint64
+		for _, d := range e.PerftDivide(depth, !steps) {
+			move := "pass"
+			if d.Move != nil {
+				move = d.Move.String()
+			}
+			e.Logf("%s %d", move, d.Leaves)
+			total += d.Leaves
+		}
+		if steps {
+			e.Logf("steps %d", total)
+		} else {
+			e.Logf("turns %d", total)
+		}
 		return nil
 	}))
 	RegisterAEIHandler("random", extendedHandler(func(e *Engine, args string) error {
@@ -240,35 +299,106 @@ func init() {
 		return nil
 	}))
 	RegisterAEIHandler("playbatch", extendedHandler(func(e *Engine, args string) error {
-		r := rand.New(rand.NewSource(time.Now().UnixNano()))
-		for n := 1; n <= e.PlayBatchGames; n++ {
-			e.RandomSetup(r)
-			var result Value
-			moves := 0
-			i := 0
-			const maxTurns = 600
-			for ; i < maxTurns && !result.Terminal(); i, result = i+1, e.Terminal() {
-				e.GoWait()
-				e.Move(e.bestMove)
-				moves++
+		// Self-play games sample moves with root noise so that games are diverse.
+		e.selfPlay = true
+		defer func() { e.selfPlay = false }()
+		n := 1
+		if w, ok := e.batchWriter.(*BatchWriter); ok {
+			// Resume after the games recovered from an interrupted run.
+			if n = w.Games() + 1; n > 1 {
+				e.Debugf("Resuming after %d finished games", w.Games())
 			}
-			if i >= maxTurns {
-				path := filepath.Join(os.TempDir(), "bot_alpha_zoo", fmt.Sprintf("long_game_%d.pgn", time.Now().Unix()))
-				e.Debugf("Game reached maximum length of %d turns. Logging to %s", maxTurns, path)
-				if err := ioutil.WriteFile(path, []byte(e.Pos.MoveList().String()), 0755); err != nil {
-					e.Debugf("Error writing game: %v", err)
-				}
-			} else if err := e.batchWriter.Finalize(e.Pos, result); err != nil {
+		}
+		for ; n <= e.PlayBatchGames; n++ {
+			result, ok := e.playSelfPlayGame(selfPlayMaxTurns)
+			if err := e.finishSelfPlayGame(result, ok); err != nil {
 				return err
 			}
 			e.Debugf("%s", e.Pos.String())
-			if c := e.Side(); result == 1 {
+			switch c := e.Side(); {
+			case !ok:
+				e.Debugf("game %d of %d reached maximum length of %d turns", n, e.PlayBatchGames, selfPlayMaxTurns)
+			case result == 1:
 				e.Debugf("%c won game %d of %d", c.Byte(), n, e.PlayBatchGames)
-			} else {
+			default:
 				e.Debugf("%c lost game %d of %d", c.Byte(), n, e.PlayBatchGames)
 			}
 		}
-		e.batchWriter.Flush()
+		return e.batchWriter.Flush()
+	}))
+	RegisterAEIHandler("selfplay", extendedHandler(func(e *Engine, args string) error {
+		// selfplay N
+		// Plays PlayBatchGames self-play games on N engines in parallel which share the model.
+		// The engines are configured by the engine flags.
+		workers, err := strconv.Atoi(strings.TrimSpace(args))
+		if err != nil || workers < 1 {
+			return fmt.Errorf("bad number of workers: %q", args)
+		}
+		runID := e.DatasetRunID
+		if runID == "" {
+			runID = NewRunID()
+		}
+		pool, err := NewSelfPlayPool(e.EngineSettings, e.AEISettings, e.model, workers, workers*e.goroutines(), runID, e.r.Int63())
+		if err != nil {
+			return err
+		}
+		defer pool.Close()
+		pool.Progress = func(s SelfPlayStats) {
+			e.Debugf("game %d of %d: %.1f games/hour", s.Games, e.PlayBatchGames, s.GamesPerHour())
+		}
+		stats, err := pool.Play(e.PlayBatchGames)
+		if err != nil {
+			return err
+		}
+		e.Logf("selfplay %d games in %s: %.1f games/hour", stats.Games, stats.Elapsed.Round(time.Second), stats.GamesPerHour())
+		return nil
+	}))
+	RegisterAEIHandler("playmatch", extendedHandler(func(e *Engine, args string) error {
+		// playmatch N [weights=PATH] [name=value ...]
+		// Plays N games against a candidate engine configured by the engine flags with the
+		// given ResNet weights and options. Scores are logged for the candidate.
+		fields := strings.Fields(args)
+		if len(fields) == 0 {
+			return fmt.Errorf("missing number of games")
+		}
+		games, err := strconv.Atoi(fields[0])
+		if err != nil || games <= 0 {
+			return fmt.Errorf("bad number of games: %q", fields[0])
+		}
+		settings := *e.EngineSettings
+		settings.UseDatasetWriter = false
+		settings.Options = append(SetoptionFlag(nil), e.EngineSettings.Options...)
+		for _, f := range fields[1:] {
+			if path := strings.TrimPrefix(f, "weights="); path != f {
+				settings.UseSavedModel = false
+				settings.ModelServerPath = ""
+				settings.ResNetWeightsPath = path
+				continue
+			}
+			if err := settings.Options.Set(f); err != nil {
+				return err
+			}
+		}
+		candidate, err := NewEngine(&settings, e.AEISettings)
+		if err != nil {
+			return err
+		}
+		defer candidate.model.Close()
+		candidate.log, candidate.out, candidate.debug = e.log, e.out, e.debug
+
+		// Games are recorded by the match rather than by the engine search.
+		match := &EngineMatch{Engines: [2]*Engine{candidate, e}}
+		if e.UseDatasetWriter {
+			match.Writer = e.batchWriter
+			e.UseDatasetWriter = false
+			defer func() { e.UseDatasetWriter = true }()
+		}
+		score, err := match.Play(games)
+		if err != nil {
+			return err
+		}
+		diff, margin := score.Elo()
+		e.Logf("candidate %d wins %d losses %d draws elo %+.1f +/- %.1f", score.Wins, score.Losses, score.Draws, diff, margin)
 		return nil
 	}))
 	RegisterAEIHandler("options", extendedHandler(func(e *Engine, args string) error {
@@ -3,13 +3,13 @@ package zoo
 import (
 	"math"
 	"math/rand"
+	"sync"
 )
 
 // DummyModel implements a dummy model giving random evaluations.
 type DummyModel struct {
-	r      *rand.Rand
-	value  float32
-	policy []float32
+	m sync.Mutex // guards r.
+	r *rand.Rand
 }
 
 // NewDummyModel creates a new dummy evaluator.
@@ -21,28 +21,26 @@ func NewDummyModel() *DummyModel {
 
 // SetSeed reseeds the random state for this dummy model.
 func (m *DummyModel) SetSeed(seed int64) {
+	m.m.Lock()
+	defer m.m.Unlock()
 	m.r = rand.New(rand.NewSource(seed))
 }
 
-// EvaluatePosition regenerates random outputs for the position.
-func (m *DummyModel) EvaluatePosition(p *Pos) {
-	if m.policy == nil {
-		m.policy = policyPool.Get().([]float32)
+// EvaluateBatch generates random outputs for the positions.
+func (m *DummyModel) EvaluateBatch(ps []*Pos) (values []float32, policies [][]float32) {
+	m.m.Lock()
+	defer m.m.Unlock()
+	values = make([]float32, len(ps))
+	policies = make([][]float32, len(ps))
+	for i := range ps {
+		values[i] = float32(math.Tanh(0.5 * m.r.NormFloat64()))
+		policy := make([]float32, modelOutputPolicySize)
+		for j := range policy {
+			policy[j] = float32(-m.r.ExpFloat64())
+		}
+		policies[i] = policy
 	}
-	m.value = float32(math.Tanh(0.5 * m.r.NormFloat64()))
-	for i := range m.policy {
-		m.policy[i] = float32(-m.r.ExpFloat64())
-	}
-}
-
-// Value returns a randomly generated value estimate for the last position.
-func (m *DummyModel) Value() float32 {
-	return m.value
-}
-
-// Policy populates the policy with randomly generated logits for the last position.
-func (m *DummyModel) Policy(policy []float32) {
-	copy(policy, m.policy)
+	return values, policies
 }
 
 // Close is a noop for the dummy model.
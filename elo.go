@@ -0,0 +1,156 @@
+package zoo
+
+import (
+	"fmt"
+	"math"
+
+	zoopb "github.com/ajzaff/bot_zoo/proto"
+)
+
+// eloZ is the normal quantile of the two sided 95% confidence interval.
+const eloZ = 1.959964
+
+// MatchScore counts the results of games between two players
+// from the perspective of the first player.
+type MatchScore struct {
+	Wins, Losses, Draws int
+}
+
+// Games returns the number of games played.
+func (s MatchScore) Games() int {
+	return s.Wins + s.Losses + s.Draws
+}
+
+// Score returns the mean score per game counting draws as half a win.
+func (s MatchScore) Score() float64 {
+	return (float64(s.Wins) + float64(s.Draws)/2) / float64(s.Games())
+}
+
+// eloPrior is the number of pseudo-games, half won and half lost, added to the results
+// before estimating so that the estimate stays finite when one player won no game.
+// Adding them to every score keeps the estimate smooth as results come in.
+const eloPrior = 1
+
+// stats returns the number of games, the mean score and the variance of the score of a
+// single game including the prior games.
+func (s MatchScore) stats() (n, mean, variance float64) {
+	w := float64(s.Wins) + eloPrior/2.0
+	l := float64(s.Losses) + eloPrior/2.0
+	d := float64(s.Draws)
+	n = w + l + d
+	mean = (w + d/2) / n
+	variance = (w*(1-mean)*(1-mean) + l*mean*mean + d*(0.5-mean)*(0.5-mean)) / n
+	return n, mean, variance
+}
+
+// Elo returns the Elo difference of the first player and the margin of its 95% confidence interval.
+// The score includes the prior games of eloPrior so that both are finite. The interval is symmetric in Elo. Both are NaN when no games were played.
+func (s MatchScore) Elo() (diff, margin float64) {
+	if s.Games() == 0 {
+		return math.NaN(), math.NaN()
+	}
+	n, m, v := s.stats()
+	// The derivative of eloDiff at m scales the standard error of the score to Elo.
+	slope := 400 / math.Ln10 / (m * (1 - m))
+	return eloDiff(m), eloZ * slope * math.Sqrt(v/n)
+}
+
+// AddMatch adds the games of match m to s from the perspective of player.
+// Games without a winner count as draws.
+func (s *MatchScore) AddMatch(m *zoopb.Match, player string) error {
+	first := -1
+	for i, name := range m.Players {
+		if name == player {
+			first = i
+		}
+	}
+	if first < 0 {
+		return fmt.Errorf("match %q: player %q not found in %q", m.Id, player, m.Players)
+	}
+	for _, g := range m.Games {
+		if int(g.GoldPlayer) != first && int(g.SilverPlayer) != first {
+			continue
+		}
+		result := g.GetPgn().GetResult()
+		if int(g.SilverPlayer) == first {
+			result = -result
+		}
+		switch {
+		case result > 0:
+			s.Wins++
+		case result < 0:
+			s.Losses++
+		default:
+			s.Draws++
+		}
+	}
+	return nil
+}
+
+// eloDiff returns the Elo difference which gives an expected score of m.
+func eloDiff(m float64) float64 {
+	return -400 * math.Log10(1/m-1)
+}
+
+// expectedScore returns the expected score of a player elo points stronger than the opponent.
+func expectedScore(elo float64) float64 {
+	return 1 / (1 + math.Pow(10, -elo/400))
+}
+
+// SPRTResult is the outcome of a sequential probability ratio test.
+type SPRTResult uint8
+
+const (
+	SPRTContinue SPRTResult = iota // more games are needed.
+	SPRTAcceptH0                   // the Elo difference is at most Elo0.
+	SPRTAcceptH1                   // the Elo difference is at least Elo1.
+)
+
+var sprtResultStrings = []string{"continue", "accept H0", "accept H1"}
+
+func (r SPRTResult) String() string {
+	if int(r) < len(sprtResultStrings) {
+		return sprtResultStrings[r]
+	}
+	return "unknown"
+}
+
+// SPRT is a sequential probability ratio test of the hypothesis H0 that the Elo difference
+// of the first player is Elo0 against H1 that it is Elo1. Alpha and Beta are the probabilities
+// of accepting H1 when H0 holds and of accepting H0 when H1 holds.
+type SPRT struct {
+	Elo0, Elo1  float64
+	Alpha, Beta float64
+}
+
+// Bounds returns the log likelihood ratios below which H0 and above which H1 is accepted.
+func (t SPRT) Bounds() (lower, upper float64) {
+	return math.Log(t.Beta / (1 - t.Alpha)), math.Log((1 - t.Beta) / t.Alpha)
+}
+
+// LLR returns the log likelihood ratio of H1 to H0 given the score s.
+// The ratio is approximated by normal distributions of the mean score.
+// The score includes the prior games of eloPrior so that a clean sweep still moves the test.
+// It is 0 when no games were played.
+func (t SPRT) LLR(s MatchScore) float64 {
+	if s.Games() == 0 {
+		return 0
+	}
+	n, m, v := s.stats()
+	s0, s1 := expectedScore(t.Elo0), expectedScore(t.Elo1)
+	return n * (s1 - s0) * (2*m - s0 - s1) / (2 * v)
+}
+
+// Test returns the log likelihood ratio and the outcome of the test given the score s.
+func (t SPRT) Test(s MatchScore) (llr float64, r SPRTResult) {
+	llr = t.LLR(s)
+	lower, upper := t.Bounds()
+	switch {
+	case llr >= upper:
+		return llr, SPRTAcceptH1
+	case llr <= lower:
+		return llr, SPRTAcceptH0
+	default:
+		return llr, SPRTContinue
+	}
+}
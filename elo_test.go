@@ -0,0 +1,96 @@
+package zoo
+
+import (
+	"math"
+	"testing"
+
+	zoopb "github.com/ajzaff/bot_zoo/proto"
+)
+
+// near returns true if got is within 1e-3 of want or both are the same infinity or NaN.
+func near(got, want float64) bool {
+	return got == want || math.Abs(got-want) < 1e-3 || math.IsNaN(got) && math.IsNaN(want)
+}
+
+func TestMatchScoreElo(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		score  MatchScore
+		diff   float64
+		margin float64
+	}{
+		{"wins", MatchScore{Wins: 60, Losses: 40}, 69.720, 69.127},
+		{"even with draws", MatchScore{Wins: 30, Losses: 30, Draws: 40}, 0, 52.658},
+		{"all wins", MatchScore{Wins: 10}, 528.888, 492.844},
+		{"all wins but one", MatchScore{Wins: 10, Losses: 1}, 338.039, 297.196},
+		{"all losses", MatchScore{Losses: 10}, -528.888, 492.844},
+		{"one win", MatchScore{Wins: 1}, 190.849, 556.003},
+		{"no games", MatchScore{}, math.NaN(), math.NaN()},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			diff, margin := tc.score.Elo()
+			if !near(diff, tc.diff) {
+				t.Errorf("Elo(): got diff %v, want %v", diff, tc.diff)
+			}
+			if !near(margin, tc.margin) {
+				t.Errorf("Elo(): got margin %v, want %v", margin, tc.margin)
+			}
+		})
+	}
+}
+
+func TestSPRT(t *testing.T) {
+	sprt := SPRT{Elo0: 0, Elo1: 10, Alpha: 0.05, Beta: 0.05}
+	lower, upper := sprt.Bounds()
+	if !near(lower, -2.944) || !near(upper, 2.944) {
+		t.Errorf("Bounds(): got [%v, %v], want [-2.944, 2.944]", lower, upper)
+	}
+	for _, tc := range []struct {
+		name  string
+		score MatchScore
+		llr   float64
+		want  SPRTResult
+	}{
+		{"stronger", MatchScore{Wins: 600, Losses: 400}, 5.563, SPRTAcceptH1},
+		{"weaker", MatchScore{Wins: 400, Losses: 600}, -6.426, SPRTAcceptH0},
+		{"undecided", MatchScore{Wins: 52, Losses: 48}, 0.073, SPRTContinue},
+		{"all wins", MatchScore{Wins: 60}, 52.316, SPRTAcceptH1},
+		{"all losses", MatchScore{Losses: 60}, -53.869, SPRTAcceptH0},
+		{"few wins", MatchScore{Wins: 5}, 0.463, SPRTContinue},
+		{"no games", MatchScore{}, 0, SPRTContinue},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			llr, r := sprt.Test(tc.score)
+			if !near(llr, tc.llr) {
+				t.Errorf("Test(): got LLR %v, want %v", llr, tc.llr)
+			}
+			if r != tc.want {
+				t.Errorf("Test(): got %v, want %v", r, tc.want)
+			}
+		})
+	}
+}
+
+func TestMatchScoreAddMatch(t *testing.T) {
+	m := &zoopb.Match{
+		Id:      "test",
+		Players: []string{"a", "b"},
+		Games: []*zoopb.Match_Game{
+			{GoldPlayer: 0, SilverPlayer: 1, Pgn: &zoopb.PGN{Result: 1}},
+			{GoldPlayer: 1, SilverPlayer: 0, Pgn: &zoopb.PGN{Result: 1}},
+			{GoldPlayer: 0, SilverPlayer: 1, Pgn: &zoopb.PGN{Result: -1}},
+			{GoldPlayer: 1, SilverPlayer: 0, Pgn: &zoopb.PGN{Result: -1}},
+			{GoldPlayer: 1, SilverPlayer: 0, Pgn: &zoopb.PGN{}},
+		},
+	}
+	var s MatchScore
+	if err := s.AddMatch(m, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if want := (MatchScore{Wins: 2, Losses: 2, Draws: 1}); s != want {
+		t.Errorf("AddMatch(b): got %+v, want %+v", s, want)
+	}
+	if err := s.AddMatch(m, "c"); err == nil {
+		t.Errorf("AddMatch(c): got nil error, want error")
+	}
+}
@@ -3,11 +3,13 @@ package zoo
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"log"
 	"math/rand"
 	"os"
 	"strings"
 	"sync/atomic"
+	"time"
 )
 
 // Engine implements game control structures around Pos and keeps track of game state.
@@ -24,26 +26,51 @@ type Engine struct {
 	*Pos
 
 	timeControl TimeControl
-	timeInfo    *TimeInfo
+	timeInfo    *TimeInfo        // game clock or nil if searches are not timed.
+	timed       bool             // a time control was given by the time_control flag or AEI options.
+	now         func() time.Time // clock used for time management; replaced in tests.
 
 	searchState
 }
 
 func NewEngine(settings *EngineSettings, aeiSettings *AEISettings) (*Engine, error) {
+	return newEngine(settings, aeiSettings, nil)
+}
+
+// newEngine is like NewEngine but searches with model instead of loading one if model is not nil.
+func newEngine(settings *EngineSettings, aeiSettings *AEISettings, model ModelInterface) (*Engine, error) {
 	e := &Engine{
 		EngineSettings: settings,
 		AEISettings:    aeiSettings,
 		Options:        newOptions(),
 		timeControl:    makeTimeControl(),
+		now:            time.Now,
 		Pos:            NewEmptyPosition(),
 		log:            log.New(os.Stdout, "log ", 0),
 		out:            log.New(os.Stdout, "", 0),
 		debug:          log.New(os.Stderr, "", 0),
 	}
+	e.model = model
 	if settings.UseDatasetWriter {
-		e.batchWriter = NewBatchWriter(settings.DatasetEpoch)
+		runID := settings.DatasetRunID
+		if runID == "" {
+			runID = NewRunID()
+		}
+		w, err := NewBatchWriter(settings.DatasetDir, settings.DatasetEpoch, runID)
+		if err != nil {
+			return nil, err
+		}
+		e.batchWriter = w
 	}
-	if err := e.EngineSettings.Options.Execute(e.Options); err != nil {
+	if settings.TimeControl != "" {
+		tc, err := ParseTimeControl(settings.TimeControl)
+		if err != nil {
+			return nil, err
+		}
+		e.timeControl = tc
+		e.timed = true
+	}
+	if err := e.EngineSettings.Options.Execute(e); err != nil {
 		return nil, err
 	}
 	if err := e.searchState.Reset(settings); err != nil {
@@ -56,7 +83,72 @@ func (e *Engine) NewGame() {
 	e.Pos = NewEmptyPosition()
 	e.tt.Clear()
 	e.searchState.Reset(e.EngineSettings)
-	e.timeInfo = e.timeControl.newTimeInfo()
+	e.timeInfo = nil
+	if e.timed {
+		e.timeInfo = e.timeControl.NewTimeInfo(e.now())
+	}
+}
+
+// startClock times the searches from now on.
+// The game clock starts unless it is already running.
+func (e *Engine) startClock() {
+	e.timed = true
+	if e.timeInfo == nil {
+		e.timeInfo = e.timeControl.NewTimeInfo(e.now())
+	}
+}
+
+// SetModel replaces the model used by the search.
+func (e *Engine) SetModel(m ModelInterface) {
+	e.model = m
+}
+
+// MakeMove plays the move m on the engine position.
+// The search tree under m is kept so the next search starts warm.
+func (e *Engine) MakeMove(m Move) {
+	e.Move(m)
+	e.tree.Advance(m, e.Pos)
+}
+
+// ExecuteSetOption executes the setoption command on the engine Options.
+// Time control options are applied to the game clock as well and start it,
+// so searches only use the clock once a time control was given.
+func (e *Engine) ExecuteSetOption(s string) error {
+	name, value, err := e.Options.executeSetOption(s)
+	if err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case int:
+		if strings.HasPrefix(name, "tc") || name == "greserve" || name == "sreserve" {
+			e.startClock()
+		}
+		e.timeControl.setOption(e.timeInfo, name, v)
+	case TimeControl:
+		e.timeControl.set(e.timeInfo, v)
+		e.startClock()
+	}
+	return nil
+}
+
+// endTurn stops the clock for the side to move and starts the clock for the opponent.
+func (e *Engine) endTurn() {
+	if e.timeInfo != nil {
+		e.timeControl.EndTurn(e.timeInfo, e.Side(), e.now())
+	}
+}
+
+// stepTree returns a tree whose policy plays the step s.
+// It is used to write examples for steps which were not searched.
+func stepTree(s Step) *Tree {
+	return &Tree{
+		root: &TreeNode{
+			children: []*TreeNode{{
+				step: s,
+				runs: 1,
+			}},
+		},
+	}
 }
 
 // RandomSetup initializes the game with random setup moves.
@@ -75,27 +167,23 @@ func (e *Engine) RandomSetup(r *rand.Rand) {
 		stepList.Truncate(j)
 		s := stepList.At(r.Intn(stepList.Len())).Step
 		if e.UseDatasetWriter {
-			e.batchWriter.WriteExample(e.Pos, &Tree{
-				root: &TreeNode{
-					children: []*TreeNode{{
-						step: s,
-						runs: 1,
-					}},
-				},
-			})
+			e.batchWriter.WriteExample(e.Pos, stepTree(s))
 		}
 		e.Step(s)
 		stepList.Truncate(0)
 	}
 }
 
-func (e *Engine) startNow(ponder bool) {
+func (e *Engine) startNow(mode searchMode) {
 	defer func() {
 		if r := recover(); r != nil {
 			panic(fmt.Sprintf("SEARCH_ERROR recovered: %v", r))
 		}
 	}()
-	go e.searchRoot(ponder)
+	// Add the search before starting it so that a Stop
+	// arriving before the goroutine runs still waits for it.
+	e.wg.Add(1)
+	go e.searchRoot(mode)
 }
 
 // GoWait starts the search routine and waits for it to finish.
@@ -106,27 +194,37 @@ func (e *Engine) GoWait() {
 				panic(fmt.Sprintf("SEARCH_ERROR recovered: %v", r))
 			}
 		}()
-		e.searchRoot(false)
+		e.wg.Add(1)
+		e.searchRoot(searchNormal)
 	}
 }
 
 // Go starts the search routine in a new goroutine.
 func (e *Engine) Go() {
 	if atomic.CompareAndSwapInt32(&e.running, 0, 1) {
-		e.startNow(false)
+		e.startNow(searchNormal)
 	}
 }
 
 // GoPonder starts the ponder search in a new goroutine.
+// Pondering searches the current position until Stop is called without printing
+// the best move. The tree is kept so the search continues after the opponent's move.
+// GoPonder does nothing if pondering is disabled.
 func (e *Engine) GoPonder() {
+	if !e.UsePonder {
+		return
+	}
 	if atomic.CompareAndSwapInt32(&e.running, 0, 1) {
-		e.startNow(true)
+		e.startNow(searchPonder)
 	}
 }
 
-// GoInfinite starts an infinite routine (same as GoPonder).
+// GoInfinite starts an infinite search in a new goroutine.
+// The search runs until Stop is called and then prints the best move.
 func (e *Engine) GoInfinite() {
-	e.GoPonder()
+	if atomic.CompareAndSwapInt32(&e.running, 0, 1) {
+		e.startNow(searchInfinite)
+	}
 }
 
 // Stop signals the search to stop immediately.
@@ -143,6 +241,11 @@ func (e *Engine) Close() (err error) {
 	if err1 := e.searchState.model.Close(); err1 != nil && err == nil {
 		err = err1
 	}
+	if w, ok := e.batchWriter.(io.Closer); ok {
+		if err1 := w.Close(); err1 != nil && err == nil {
+			err = err1
+		}
+	}
 	return err
 }
 
@@ -150,6 +253,7 @@ func (e *Engine) Close() (err error) {
 func (e *Engine) Debug() {
 	e.Debugf(e.Pos.String())
 	e.Debugf("short=%s", e.ShortString())
+	e.Debugf("full=%s", e.FullString())
 	e.Debugf("hash=%v", e.Hash())
 
 	src, piece, ok := e.Push()
@@ -2,15 +2,37 @@ package zoo
 
 import expb "github.com/ajzaff/bot_zoo/proto"
 
-func featureBitset(ex *expb.Example, c Color, p Piece) *expb.Example_Bitset {
-	idx := uint32(p.RemoveColor())
+// Feature plane indices as documented on Features.
+const (
+	ourPiecePlanes   = 0
+	theirPiecePlanes = 6
+	pushPiecePlanes  = 12
+	inPushPlane      = 18
+	lastStepPlane    = 19
+	setupPlane       = 20
+	goalPlane        = 21
+	featurePlanes    = 22
+)
+
+// piecePlane returns the plane index of piece p from the perspective of side c.
+func piecePlane(c Color, p Piece) int {
+	idx := ourPiecePlanes
 	if c != p.Color() {
-		idx += 6
+		idx = theirPiecePlanes
 	}
-	b := ex.Bitsets[idx]
+	return idx + int(p.RemoveColor()-GRabbit)
+}
+
+// pushPiecePlane returns the plane index of the push mask for piece p.
+func pushPiecePlane(p Piece) int {
+	return pushPiecePlanes + int(p.RemoveColor()-GRabbit)
+}
+
+func featureBitset(ex *expb.Example, idx int) *expb.Example_Bitset {
+	b := ex.Bitsets[uint32(idx)]
 	if b == nil {
 		b = &expb.Example_Bitset{}
-		ex.Bitsets[idx] = b
+		ex.Bitsets[uint32(idx)] = b
 	}
 	return b
 }
@@ -35,7 +57,7 @@ func clearFeatures(ex *expb.Example) {
 	ex.Bitsets = make(map[uint32]*expb.Example_Bitset)
 }
 
-// Features fills in the flat features slice which has the shape (8 * 8 * 21,)
+// Features fills in the flat features slice which has the shape (8 * 8 * 22,)
 // with features extracted from p. This slice has all the necessary components
 // for encoding an Arimaa position and can be reshaped to directly input into
 // the network.
@@ -45,7 +67,9 @@ func clearFeatures(ex *expb.Example) {
 //	Push piece mask (6 planes),
 //	In push?        (1 plane; all 0 or 1),
 //	Last turn?      (1 plane; all 0 or 1),
-//	Setup?          (1 plane; all 0 or 1).
+//	Setup?          (1 plane; all 0 or 1),
+//	Goal threat     (1 plane; squares stepped to by a goal of the side to move).
+// The goal threat plane is computed by goalThreat from the encoded state alone.
 // Positions are flipped as necessary to ensure the side to move is relative to
 // Gold's perspective of the board (with home rank of A, and goal rank of H).
 // lateralFeats is filled with board features mirrored laterally (for dataset
@@ -57,29 +81,133 @@ func Features(p *Pos, ex *expb.Example) {
 	for i := A1; i <= H8; i++ {
 		p := p.At(i)
 		if p != Empty {
-			b := featureBitset(ex, c, p)
+			b := featureBitset(ex, piecePlane(c, p))
 			b.Ones = append(b.Ones, featureIndex(c, i))
 		}
 	}
 
 	if src, piece, ok := p.Push(); src.Valid() {
-		idx := 12 + uint32(piece.RemoveColor())
-		b := ex.Bitsets[idx]
-		if b == nil {
-			b = &expb.Example_Bitset{}
-			ex.Bitsets[idx] = b
+		b := featureBitset(ex, pushPiecePlane(piece))
+		b.Ones = append(b.Ones, featureIndex(c, src))
+		if ok {
+			ex.Bitsets[inPushPlane] = &expb.Example_Bitset{AllOnes: true}
+		}
+	}
+
+	if p.LastStep() {
+		ex.Bitsets[lastStepPlane] = &expb.Example_Bitset{AllOnes: true}
+	}
+
+	if p.MoveNum() == 1 {
+		ex.Bitsets[setupPlane] = &expb.Example_Bitset{AllOnes: true}
+	}
+
+	if m, ok := goalThreat(p); ok {
+		b := featureBitset(ex, goalPlane)
+		for _, s := range m {
+			if !s.Capture() {
+				b.Ones = append(b.Ones, featureIndex(c, s.Dest()))
+			}
+		}
+	}
+}
+
+// DenseFeatures fills the dense input tensor with the shape (8, 8, 22) with
+// features extracted from p. The tensor is indexed by rank, file and plane.
+// The planes and orientation are the same as those of Features.
+func DenseFeatures(p *Pos, input [][][]float32) {
+	c := p.Side()
+
+	for _, rank := range input {
+		for _, planes := range rank {
+			for k := range planes {
+				planes[k] = 0
+			}
 		}
-		b.Ones = append(b.Ones, uint32(src))
+	}
+	fill := func(idx int) {
+		for _, rank := range input {
+			for _, planes := range rank {
+				planes[idx] = 1
+			}
+		}
+	}
+
+	for i := A1; i <= H8; i++ {
+		piece := p.At(i)
+		if piece != Empty {
+			j := featureIndex(c, i)
+			input[j/8][j%8][piecePlane(c, piece)] = 1
+		}
+	}
+
+	if src, piece, ok := p.Push(); src.Valid() {
+		j := featureIndex(c, src)
+		input[j/8][j%8][pushPiecePlane(piece)] = 1
 		if ok {
-			ex.Bitsets[18] = &expb.Example_Bitset{AllOnes: true}
+			fill(inPushPlane)
 		}
 	}
 
 	if p.LastStep() {
-		ex.Bitsets[19] = &expb.Example_Bitset{AllOnes: true}
+		fill(lastStepPlane)
 	}
 
 	if p.MoveNum() == 1 {
-		ex.Bitsets[20] = &expb.Example_Bitset{AllOnes: true}
+		fill(setupPlane)
+	}
+
+	if m, ok := goalThreat(p); ok {
+		for _, s := range m {
+			if !s.Capture() {
+				j := featureIndex(c, s.Dest())
+				input[j/8][j%8][goalPlane] = 1
+			}
+		}
+	}
+}
+
+// newFeaturePos returns a position with only the state encoded in the model input:
+// the board, side, steps left, push state and move number. It has no history and
+// its turn starts at the returned position.
+func newFeaturePos(board *[64]Piece, side Color, stepsLeft int, push pushInfo, moveNum int) *Pos {
+	p := NewEmptyPosition()
+	for i := A1; i <= H8; i++ {
+		if board[i] != Empty {
+			p.Place(board[i], i)
+		}
+	}
+	p.side = side
+	p.stepsLeft = stepsLeft
+	p.stack[len(p.stack)-1] = push
+	p.moveNum = moveNum
+	p.resetHistory(nil)
+	return p
+}
+
+// goalThreat returns the goal of the side to move found in the state of p encoded in the
+// model input. Unlike Pos.Goal it ignores the turn start and repetition history of p so
+// that the goal threat plane is the same wherever p is encoded.
+func goalThreat(p *Pos) (m Move, ok bool) {
+	if p.moveNum == 1 || p.goalDistance(p.Side()) > p.stepsLeft {
+		return nil, false
+	}
+	var board [64]Piece
+	for i := A1; i <= H8; i++ {
+		board[i] = p.At(i)
+	}
+	src, piece, push := p.Push()
+	return newFeaturePos(&board, p.side, p.stepsLeft, pushInfo{push, piece, src}, p.moveNum).searchGoal()
+}
+
+// newDenseFeatures allocates a dense input tensor for DenseFeatures.
+func newDenseFeatures() [][][]float32 {
+	input := make([][][]float32, 8)
+	for i := range input {
+		input[i] = make([][]float32, 8)
+		for j := range input[i] {
+			input[i][j] = make([]float32, featurePlanes)
+		}
 	}
+	return input
 }
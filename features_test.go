@@ -0,0 +1,202 @@
+package zoo
+
+import (
+	"testing"
+
+	expb "github.com/ajzaff/bot_zoo/proto"
+)
+
+// sparseToDense expands the sparse bitsets of ex to a dense tensor.
+func sparseToDense(ex *expb.Example) [][][]float32 {
+	input := newDenseFeatures()
+	for idx, b := range ex.Bitsets {
+		for i := 0; i < 64; i++ {
+			if b.AllOnes {
+				input[i/8][i%8][idx] = 1
+			}
+		}
+		for _, i := range b.Ones {
+			input[i/8][i%8][idx] = 1
+		}
+	}
+	return input
+}
+
+func TestDenseFeatures(t *testing.T) {
+	var covered [featurePlanes]bool
+	for _, tc := range []struct {
+		name          string
+		shortPosition string
+		moveNum       int
+		steps         []Step
+		wantPlanes    []int
+	}{{
+		name:          "opening gold",
+		shortPosition: "g [rrrrrrrrhdcemcdh                                HDCMECDHRRRRRRRR]",
+		wantPlanes:    []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+	}, {
+		name:          "opening silver",
+		shortPosition: "s [rrrrrrrrhdcemcdh                                HDCMECDHRRRRRRRR]",
+		wantPlanes:    []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+	}, {
+		name:          "setup",
+		shortPosition: "g [                                                                ]",
+		moveNum:       1,
+		steps: []Step{
+			MakeSetup(GElephant, E2),
+			MakeSetup(GRabbit, A1),
+		},
+		wantPlanes: []int{0, 5, setupPlane},
+	}, {
+		name:          "in push",
+		shortPosition: "g [                                  r       Cr      D             ]",
+		steps: []Step{
+			MakeStep(SRabbit, D3, D4),
+		},
+		wantPlanes: []int{1, 2, 6, pushPiecePlanes, inPushPlane},
+	}, {
+		name:          "pull available silver",
+		shortPosition: "s [          eD       r                                            ]",
+		steps: []Step{
+			MakeStep(SElephant, C7, C8),
+		},
+		wantPlanes: []int{0, 5, 8, pushPiecePlanes + 5},
+	}, {
+		name:          "last step",
+		shortPosition: "g [       r                            Ed     Cr           R       ]",
+		steps: []Step{
+			MakeStep(GCat, D3, D2),
+			MakeStep(SRabbit, E3, D3),
+			MakeStep(SRabbit, D3, E3),
+		},
+		wantPlanes: []int{0, 1, 5, 6, 8, pushPiecePlanes, inPushPlane, lastStepPlane},
+	}, {
+		name:          "step cat",
+		shortPosition: "g [                           C                                    ]",
+		steps: []Step{
+			MakeStep(GCat, D5, D6),
+		},
+		wantPlanes: []int{1, pushPiecePlanes + 1},
+	}, {
+		name:          "step dog",
+		shortPosition: "g [                           D                                    ]",
+		steps: []Step{
+			MakeStep(GDog, D5, D6),
+		},
+		wantPlanes: []int{2, pushPiecePlanes + 2},
+	}, {
+		name:          "step horse",
+		shortPosition: "g [                           H                                    ]",
+		steps: []Step{
+			MakeStep(GHorse, D5, D6),
+		},
+		wantPlanes: []int{3, pushPiecePlanes + 3},
+	}, {
+		name:          "step camel silver",
+		shortPosition: "s [                           m                                    ]",
+		steps: []Step{
+			MakeStep(SCamel, D5, D4),
+		},
+		wantPlanes: []int{4, pushPiecePlanes + 4},
+	}, {
+		name:          "goal threat",
+		shortPosition: "g [               rR              e                            E   ]",
+		wantPlanes:    []int{0, 5, 6, 11, goalPlane},
+	}, {
+		name:          "goal threat silver",
+		shortPosition: "s [                                                r           e E ]",
+		wantPlanes:    []int{0, 5, 11, goalPlane},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := ParseShortPosition(tc.shortPosition)
+			if err != nil {
+				t.Fatalf("ParseShortPosition(%q): %v", tc.shortPosition, err)
+			}
+			if tc.moveNum != 0 {
+				p.moveNum = tc.moveNum
+				if p.moveNum == 1 {
+					p.stepsLeft = 16
+				}
+			}
+			for _, step := range tc.steps {
+				if !p.Legal(step) {
+					t.Log(p)
+					t.Fatalf("Intermediate step is not legal: %s", step)
+				}
+				p.Step(step)
+			}
+
+			ex := &expb.Example{}
+			Features(p, ex)
+			want := sparseToDense(ex)
+			got := newDenseFeatures()
+			DenseFeatures(p, got)
+
+			var gotPlanes []int
+			for k := 0; k < featurePlanes; k++ {
+				var nonzero bool
+				for i := 0; i < 64; i++ {
+					if g, w := got[i/8][i%8][k], want[i/8][i%8][k]; g != w {
+						t.Errorf("DenseFeatures(): plane %d at %s: got %v, want %v", k, Square(i), g, w)
+					}
+					nonzero = nonzero || got[i/8][i%8][k] != 0
+				}
+				if nonzero {
+					gotPlanes = append(gotPlanes, k)
+					covered[k] = true
+				}
+			}
+			if len(gotPlanes) != len(tc.wantPlanes) {
+				t.Fatalf("DenseFeatures(): got nonzero planes %v, want %v", gotPlanes, tc.wantPlanes)
+			}
+			for i := range gotPlanes {
+				if gotPlanes[i] != tc.wantPlanes[i] {
+					t.Fatalf("DenseFeatures(): got nonzero planes %v, want %v", gotPlanes, tc.wantPlanes)
+				}
+			}
+		})
+	}
+	for k, ok := range covered {
+		if !ok {
+			t.Errorf("DenseFeatures(): plane %d is not covered by any test case", k)
+		}
+	}
+}
+
+func TestDenseFeaturesClears(t *testing.T) {
+	p, err := ParseShortPosition("g [rrrrrrrrhdcemcdh                                HDCMECDHRRRRRRRR]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := newDenseFeatures()
+	for _, rank := range input {
+		for _, planes := range rank {
+			for k := range planes {
+				planes[k] = 1
+			}
+		}
+	}
+	DenseFeatures(p, input)
+	if got := input[4][4][setupPlane]; got != 0 {
+		t.Errorf("DenseFeatures(): got stale setup plane %v, want 0", got)
+	}
+	if got := input[0][0][piecePlane(Gold, GRabbit)]; got != 1 {
+		t.Errorf("DenseFeatures(): got gold rabbit a1 %v, want 1", got)
+	}
+	if got := input[7][0][piecePlane(Gold, SRabbit)]; got != 1 {
+		t.Errorf("DenseFeatures(): got silver rabbit a8 %v, want 1", got)
+	}
+
+	// Silver sees the board flipped with its own rabbits on the home rank.
+	p, err = ParseShortPosition("s [rrrrrrrrhdcemcdh                                HDCMECDHRRRRRRRR]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	DenseFeatures(p, input)
+	if got := input[0][0][piecePlane(Silver, SRabbit)]; got != 1 {
+		t.Errorf("DenseFeatures(): got flipped silver rabbit h8 %v, want 1", got)
+	}
+	if got := input[6][3][piecePlane(Silver, GElephant)]; got != 1 {
+		t.Errorf("DenseFeatures(): got flipped gold elephant e2 %v, want 1", got)
+	}
+}
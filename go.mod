@@ -0,0 +1,10 @@
+module github.com/ajzaff/bot_zoo
+
+go 1.23
+
+require (
+	github.com/golang/protobuf v1.5.4
+	github.com/golang/snappy v1.0.0
+	github.com/google/go-cmp v0.7.0
+	google.golang.org/protobuf v1.36.12
+)
@@ -0,0 +1,255 @@
+package zoo
+
+// fileA is the bitboard of the A file.
+const fileA Bitboard = ^NotFileA
+
+// goalRank returns the goal rank of side c.
+func goalRank(c Color) Bitboard {
+	if c == Gold {
+		return ^NotRank8
+	}
+	return ^NotRank1
+}
+
+// goalDistance returns a lower bound on the steps any rabbit of side c needs to reach goal.
+// It returns 8 if c has no rabbits.
+func (p *Pos) goalDistance(c Color) int {
+	d := 8
+	for b := p.bitboards[GRabbit.WithColor(c)]; b > 0; b &= b - 1 {
+		if r, _ := p.rabbitGoalDistance(c, b.Square()); r < d {
+			d = r
+		}
+	}
+	return d
+}
+
+// rabbitGoalDistance returns a lower bound on the steps the rabbit of side c at i needs
+// to reach goal. A rabbit needs one step for each rank and one more if it is frozen or a
+// piece stands in its path, since another step or a sideways step comes first. It needs
+// two more if an enemy piece which cannot be pulled stands in front of it and both sides
+// are occupied, since the enemy piece takes a push or pull to move and a sideways step
+// needs a square cleared.
+// The bound only changes by a step to or from a square of area.
+func (p *Pos) rabbitGoalDistance(c Color, i Square) (d int, area Bitboard) {
+	d = int(i.Rank())
+	b := i.Bitboard()
+	path := fileA << i.File()
+	var ahead Bitboard
+	if c == Gold {
+		d = 7 - d
+		path &= ^(b<<1 - 1)
+		ahead = b << 8
+	} else {
+		path &= b - 1
+		ahead = b >> 8
+	}
+	// A piece leaving a square next to the enemy piece may pull it next.
+	lastSrc, _, _ := p.Push()
+	switch {
+	case d == 0:
+	case ahead&p.presence[c.Opposite()] != 0 && ahead.Neighbors()&lastSrc.Bitboard() == 0 &&
+		((b&NotFileA)>>1|(b&NotFileH)<<1)&p.Empty() == 0:
+		d += 2
+	case path&p.Nonempty() != 0 || p.Frozen(i):
+		d++
+	}
+	// Captures on traps in the path or next to the rabbit follow steps next to the trap.
+	n := b.Neighbors()
+	area = b | path | n | ahead.Neighbors() | (Traps & (path | n)).Neighbors()
+	return d, area
+}
+
+// goalResult is the result of a goal search at the position identified by key.
+type goalResult struct {
+	key goalKey
+	m   Move
+	ok  bool
+}
+
+// goalKey identifies the point of the turn a goal search ran at.
+type goalKey struct {
+	turnKey
+	moveNum   int
+	stepsLeft int
+}
+
+func (p *Pos) goalKey() goalKey {
+	return goalKey{p.turnKey(), p.moveNum, p.stepsLeft}
+}
+
+// Goal searches for a goal by the side to move within the remaining steps of the turn.
+// Pushes and pulls which clear the path for a rabbit are considered. Goal returns the
+// shortest sequence of steps (including captures) after which the turn can end with a
+// goal. The sequence is empty if the turn can end with a goal already. ok is false if
+// the side to move cannot goal this turn or during setup.
+// The result is kept on p until p changes so repeated calls do not search again.
+// The returned move must not be modified.
+func (p *Pos) Goal() (m Move, ok bool) {
+	if m, ok, known := p.knownGoal(); known {
+		return m, ok
+	}
+	m, ok = p.searchGoal()
+	p.goal = &goalResult{key: p.goalKey(), m: m, ok: ok}
+	return m, ok
+}
+
+// knownGoal returns the result of the last goal search if it ran at the current position.
+// known is false if Goal was not called since p changed.
+func (p *Pos) knownGoal() (m Move, ok, known bool) {
+	if g := p.goal; g != nil && g.key == p.goalKey() {
+		return g.m, g.ok, true
+	}
+	return nil, false, false
+}
+
+func (p *Pos) searchGoal() (m Move, ok bool) {
+	if p.moveNum == 1 || p.goalDistance(p.Side()) > p.stepsLeft {
+		return nil, false
+	}
+	// The search steps p and restores it before returning.
+	g := goalSearch{
+		p:      p,
+		c:      p.Side(),
+		failed: make(map[turnKey]int),
+	}
+	for _, n := range p.threefold.m {
+		if n >= 2 {
+			g.repeats = true
+			break
+		}
+	}
+	if g.goal() {
+		// A rabbit already reached goal during this turn.
+		return Move{}, true
+	}
+	for depth := 1; depth <= p.stepsLeft; depth++ {
+		if g.search(depth) {
+			for _, s := range g.m {
+				if !s.Capture() {
+					p.Unstep()
+				}
+			}
+			return g.m, true
+		}
+	}
+	return nil, false
+}
+
+// goalSearch is the state of a depth limited goal search.
+type goalSearch struct {
+	p       *Pos
+	c       Color           // side searching for a goal.
+	m       Move            // steps taken so far.
+	failed  map[turnKey]int // greatest depth searched without a goal by position.
+	repeats bool            // a position occurred twice so a goal may be a third repetition.
+	steps   [][]ExtStep     // generated steps by depth.
+}
+
+// search returns true if the turn can end with a goal within depth more steps.
+// Shallower goals were already searched for by iterative deepening.
+func (g *goalSearch) search(depth int) bool {
+	p := g.p
+	// A step lowers the distance of a rabbit by at most 3 and only by changing its area.
+	// When no rabbit is nearer than depth, the next step must bring one nearer.
+	d := 8
+	var area Bitboard
+	for b := p.bitboards[GRabbit.WithColor(g.c)]; b > 0; b &= b - 1 {
+		r, a := p.rabbitGoalDistance(g.c, b.Square())
+		if r < d {
+			d = r
+		}
+		if r <= depth+2 {
+			area |= a
+		}
+	}
+	if d > depth {
+		return false
+	}
+	if d < depth {
+		area = ^Bitboard(0)
+	}
+	key := p.turnKey()
+	if d, ok := g.failed[key]; ok && d >= depth {
+		return false
+	}
+	if depth == 1 {
+		// The last step of a shortest goal moves a rabbit onto the goal rank.
+		r := GRabbit.WithColor(g.c)
+		for b := p.bitboards[r]; b > 0; b &= b - 1 {
+			src := b.Square()
+			for d := src.ForwardNeighbors(g.c) & goalRank(g.c) & p.Empty(); d > 0; d &= d - 1 {
+				if g.step(MakeStep(r, src, d.Square()), depth) {
+					return true
+				}
+			}
+		}
+		g.failed[key] = depth
+		return false
+	}
+	region := ^Bitboard(0)
+	if depth == 2 && !g.repeats {
+		// With two steps left the first step moves a rabbit which can goal or changes a square
+		// next to it or next to a trap it touches. Otherwise the second step would goal alone,
+		// unless that goal is a third repetition.
+		region = 0
+		for b := p.bitboards[GRabbit.WithColor(g.c)]; b > 0; b &= b - 1 {
+			i := b.Square()
+			if r, _ := p.rabbitGoalDistance(g.c, i); r <= depth {
+				n := i.Neighbors()
+				region |= i.Bitboard() | n | n.Neighbors()
+			}
+		}
+	}
+	for len(g.steps) < depth {
+		g.steps = append(g.steps, nil)
+	}
+	steps := g.steps[depth-1][:0]
+	p.generateStepsFrom(&steps, (area|area.Neighbors())&(region|region.Neighbors()))
+	g.steps[depth-1] = steps
+	for _, s := range steps {
+		if b := s.Src().Bitboard() | s.Dest().Bitboard(); b&area == 0 || b&region == 0 {
+			continue
+		}
+		if g.step(s.Step, depth) {
+			return true
+		}
+	}
+	g.failed[key] = depth
+	return false
+}
+
+// step plays s if it is legal and returns true if the turn can end with a goal within
+// depth-1 more steps. Otherwise s is undone.
+func (g *goalSearch) step(s Step, depth int) bool {
+	p := g.p
+	if !p.Legal(s) {
+		return false
+	}
+	n := len(g.m)
+	g.m = append(g.m, s)
+	if cap := p.Step(s); cap.Capture() {
+		g.m = append(g.m, cap)
+	}
+	if g.goal() || depth > 1 && p.Side() == g.c && g.search(depth-1) {
+		return true
+	}
+	g.m = g.m[:n]
+	p.Unstep()
+	return false
+}
+
+// goal returns true if the turn can end with a goal in the current position.
+func (g *goalSearch) goal() bool {
+	p := g.p
+	if p.bitboards[GRabbit.WithColor(g.c)]&goalRank(g.c) == 0 {
+		return false
+	}
+	if p.Side() == g.c {
+		if !p.CanPass() {
+			return false
+		}
+		p.Pass()
+		defer p.Unpass()
+	}
+	return p.Terminal().Value == Loss
+}
@@ -0,0 +1,130 @@
+package zoo
+
+import (
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestGoal(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		shortPosition string
+		steps         []Step
+		want          int // number of steps in the goal or 0 if there is none.
+	}{{
+		name:          "one step",
+		shortPosition: "g [        R      r               e                            E   ]",
+		want:          1,
+	}, {
+		name:          "around a blocker",
+		shortPosition: "g [r       R      r                                            E   ]",
+		want:          2,
+	}, {
+		name:          "pull clears the path",
+		shortPosition: "g [        rE     rRD             e                                ]",
+		want:          4,
+	}, {
+		name:          "enemy in front",
+		shortPosition: "g [r       RD                        e                         E   ]",
+		want:          3,
+	}, {
+		name:          "frozen rabbit",
+		shortPosition: "g [        Rd     r               e                            E   ]",
+	}, {
+		name:          "too far",
+		shortPosition: "g [               r               e        R                   E   ]",
+	}, {
+		name:          "no steps left",
+		shortPosition: "g [               rR              e                            E   ]",
+		steps: []Step{
+			MakeStep(GElephant, E1, E2),
+			MakeStep(GElephant, E2, E3),
+			MakeStep(GElephant, E3, E4),
+		},
+	}, {
+		name:          "silver",
+		shortPosition: "s [    e                                           R      r    E   ]",
+		want:          1,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := ParseShortPosition(tc.shortPosition)
+			if err != nil {
+				t.Fatalf("ParseShortPosition(%q): %v", tc.shortPosition, err)
+			}
+			for _, step := range tc.steps {
+				if !p.Legal(step) {
+					t.Fatalf("Intermediate step is not legal: %s", step)
+				}
+				p.Step(step)
+			}
+			hash := p.Hash()
+			m, ok := p.Goal()
+			if p.Hash() != hash {
+				t.Fatalf("Goal(): position changed")
+			}
+			if !ok {
+				if tc.want != 0 {
+					t.Fatalf("Goal(): got no goal, want %d steps", tc.want)
+				}
+				return
+			}
+			if got := m.Len(); got != tc.want {
+				t.Errorf("Goal(): got %s with %d steps, want %d", m, got, tc.want)
+			}
+			p.Move(m)
+			if got := p.Terminal().Value; got != Loss {
+				t.Errorf("Move(%s): got value %v for the opponent, want %v", m, got, Loss)
+			}
+		})
+	}
+}
+
+func TestGoalCommand(t *testing.T) {
+	engine := newTestEngine(t, 0)
+	var out strings.Builder
+	engine.log = log.New(&out, "", 0)
+	for _, tc := range []struct {
+		shortPosition string
+		want          string
+	}{
+		{"g [        R      r               e                            E   ]", "Ra7n"},
+		{"g [               r               e        R                   E   ]", "no goal"},
+	} {
+		out.Reset()
+		if err := engine.ExecuteCommand("setposition " + tc.shortPosition); err != nil {
+			t.Fatal(err)
+		}
+		if err := engine.ExecuteCommand("goal"); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSpace(out.String()); !strings.HasSuffix(got, tc.want) {
+			t.Errorf("goal %s: got output %q, want %q", tc.shortPosition, got, tc.want)
+		}
+	}
+}
+
+func TestGoalKnown(t *testing.T) {
+	p, err := ParseShortPosition("g [               rR              e                            E   ]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, known := p.knownGoal(); known {
+		t.Fatalf("knownGoal(): got known before Goal")
+	}
+	m, ok := p.Goal()
+	if !ok {
+		t.Fatalf("Goal(): got no goal")
+	}
+	if got, gotOK, known := p.knownGoal(); !known || !gotOK || got.String() != m.String() {
+		t.Errorf("knownGoal(): got %s, %v, %v, want %s, true, true", got, gotOK, known, m)
+	}
+	p.Step(MakeStep(GElephant, E1, E2))
+	if _, _, known := p.knownGoal(); known {
+		t.Errorf("knownGoal(): got known after Step")
+	}
+	p.Unstep()
+	if _, _, known := p.knownGoal(); !known {
+		t.Errorf("knownGoal(): got unknown after Unstep")
+	}
+}
@@ -0,0 +1,126 @@
+package zoo
+
+import "fmt"
+
+// defaultMatchTurns is the turn limit of match games when EngineMatch.MaxTurns is 0.
+const defaultMatchTurns = 600
+
+// EngineMatch plays games between two engines in one process. Each engine searches with
+// its own model, options and playouts. Engines alternate colors and games are paired:
+// the second game of a pair replays the setups of the first with colors reversed.
+// Moves are sampled with root noise as in self-play so that pairs differ.
+type EngineMatch struct {
+	Engines  [2]*Engine
+	Writer   BatchWriterInterface // records each game if not nil.
+	MaxTurns int                  // turns after which a game is drawn or 0 for the default.
+
+	start *Pos // position games start from instead of the setup if not nil.
+}
+
+// Play plays the given number of games and returns the score of the first engine.
+// Games are logged to the first engine and flushed to the writer at the end.
+// Engines should not write examples themselves while the match records games.
+func (m *EngineMatch) Play(games int) (MatchScore, error) {
+	var (
+		score  MatchScore
+		setups [2]Move // setups by color of the first game of the pair.
+	)
+	for n := 0; n < games; n++ {
+		gold := n % 2
+		v, err := m.playGame(gold, &setups, gold == 1)
+		if err != nil {
+			return score, err
+		}
+		if gold == 1 {
+			v = -v
+		}
+		switch {
+		case v > 0:
+			score.Wins++
+		case v < 0:
+			score.Losses++
+		default:
+			score.Draws++
+		}
+		m.Engines[0].Debugf("game %d of %d: %d - %d - %d", n+1, games, score.Wins, score.Losses, score.Draws)
+	}
+	if m.Writer != nil {
+		if err := m.Writer.Flush(); err != nil {
+			return score, err
+		}
+	}
+	return score, nil
+}
+
+// playGame plays a game with the engine gold playing gold and returns the value for gold.
+// The setups are replayed if replay is set and recorded otherwise.
+func (m *EngineMatch) playGame(gold int, setups *[2]Move, replay bool) (Value, error) {
+	engines := [2]*Engine{m.Engines[gold], m.Engines[1-gold]}
+	for _, e := range engines {
+		e.NewGame()
+		if m.start != nil {
+			e.Pos = m.start.Clone()
+		}
+		// Match games are not played on a clock.
+		e.timeInfo = nil
+		e.selfPlay = true
+	}
+	defer func() {
+		for _, e := range engines {
+			e.selfPlay = false
+		}
+	}()
+	maxTurns := m.MaxTurns
+	if maxTurns == 0 {
+		maxTurns = defaultMatchTurns
+	}
+
+	var (
+		p     *Pos
+		value Value
+	)
+	for turn := 0; ; turn++ {
+		p = engines[0].Pos
+		if r := p.Terminal(); r.Terminal() {
+			value = r.Value
+			break
+		}
+		if turn >= maxTurns {
+			break
+		}
+		c := p.Side()
+		e := engines[c]
+		var move Move
+		if p.MoveNum() == 1 && replay {
+			move = setups[c]
+			if m.Writer != nil {
+				// Each step has its own one-hot policy like RandomSetup.
+				writeStepExamples(m.Writer, p.Clone(), move, func(i int) *Tree { return stepTree(move[i]) })
+			}
+		} else {
+			e.bestMove = nil
+			e.GoWait()
+			if move = e.bestMove; move == nil {
+				return 0, fmt.Errorf("no move for %c at %s", c.Byte(), p.ShortString())
+			}
+			if p.MoveNum() == 1 {
+				setups[c] = move
+			}
+			if m.Writer != nil {
+				writeExamples(m.Writer, p.Clone(), e.tree, move)
+			}
+		}
+		for _, e := range engines {
+			e.MakeMove(move)
+		}
+	}
+	if m.Writer != nil {
+		if err := m.Writer.Finalize(p, value); err != nil {
+			return 0, err
+		}
+	}
+	if p.Side() == Silver {
+		value = -value
+	}
+	return value, nil
+}
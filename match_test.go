@@ -0,0 +1,170 @@
+package zoo
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+)
+
+// gameRecorder is a BatchWriterInterface which keeps the move lists of finished games.
+type gameRecorder struct {
+	examples int
+	policies [][]float32 // runs logits of each example.
+	games    []MoveList
+	sides    []Color // side to move at the end of each game.
+	values   []Value // result for the side to move at the end of each game.
+	flushed  bool
+}
+
+func (w *gameRecorder) WriteExample(p *Pos, t *Tree) {
+	w.examples++
+	w.policies = append(w.policies, append([]float32(nil), t.Root().RunsLogits()...))
+}
+
+func (w *gameRecorder) Finalize(p *Pos, v Value) error {
+	w.games = append(w.games, p.MoveList())
+	w.sides = append(w.sides, p.Side())
+	w.values = append(w.values, v)
+	return nil
+}
+
+func (w *gameRecorder) Flush() error {
+	w.flushed = true
+	return nil
+}
+
+func TestEngineMatch(t *testing.T) {
+	w := &gameRecorder{}
+	m := &EngineMatch{
+		Engines:  [2]*Engine{newTestEngine(t, 10), newTestEngine(t, 20)},
+		Writer:   w,
+		MaxTurns: 8,
+	}
+	score, err := m.Play(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := score.Games(); got != 4 {
+		t.Errorf("Play(4): got %d games, want 4", got)
+	}
+	if len(w.games) != 4 || !w.flushed {
+		t.Fatalf("Play(4): got %d games recorded flushed=%v, want 4 games flushed", len(w.games), w.flushed)
+	}
+	if w.examples == 0 {
+		t.Errorf("Play(4): got no examples written")
+	}
+	for i := 0; i < 4; i += 2 {
+		a, b := w.games[i], w.games[i+1]
+		for c := 0; c < 2; c++ {
+			if !a[c].Equals(b[c]) {
+				t.Errorf("Play(4): game %d setup %s differs from paired game setup %s", i+2, b[c], a[c])
+			}
+		}
+	}
+}
+
+func TestEngineMatchReplayPolicies(t *testing.T) {
+	w := &gameRecorder{}
+	m := &EngineMatch{
+		Engines:  [2]*Engine{newTestEngine(t, 10), newTestEngine(t, 20)},
+		Writer:   w,
+		MaxTurns: 2, // setups only.
+	}
+	var setups [2]Move
+	if _, err := m.playGame(0, &setups, false); err != nil {
+		t.Fatal(err)
+	}
+	w.policies = nil
+	if _, err := m.playGame(1, &setups, true); err != nil {
+		t.Fatal(err)
+	}
+	// One example for the empty board and one after each step.
+	want := append(Move{setups[Gold][0]}, setups[Gold]...)
+	want = append(want, setups[Silver]...)
+	if len(w.policies) != len(want) {
+		t.Fatalf("playGame(): got %d examples, want %d", len(w.policies), len(want))
+	}
+	for i, policy := range w.policies {
+		for j, v := range policy {
+			var runs float32
+			if j == int(want[i].Index()) {
+				runs = 1
+			}
+			if v != runs {
+				t.Errorf("playGame(): example %d: got runs %v at %d, want a policy for %s", i, v, j, want[i])
+				break
+			}
+		}
+	}
+}
+
+func TestEngineMatchDecisive(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		shortPosition string
+		wantValue     Value // value of the game for gold.
+	}{{
+		name:          "gold goals",
+		shortPosition: "g [       rR                                       e           E   ]",
+		wantValue:     Win,
+	}, {
+		name:          "silver goals",
+		shortPosition: "s [                                                r           e  R]",
+		wantValue:     Loss,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := ParseShortPosition(tc.shortPosition)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := &gameRecorder{}
+			m := &EngineMatch{
+				Engines:  [2]*Engine{newTestEngine(t, 10), newTestEngine(t, 20)},
+				Writer:   w,
+				MaxTurns: 8,
+				start:    p,
+			}
+			var setups [2]Move
+			for gold := 0; gold < 2; gold++ {
+				if v, err := m.playGame(gold, &setups, false); err != nil || v != tc.wantValue {
+					t.Errorf("playGame(%d): got %v, %v, want %v for gold", gold, v, err, tc.wantValue)
+				}
+			}
+			// The side to move at the end lost to the goal.
+			for i := range w.values {
+				if w.sides[i] != p.Side().Opposite() || w.values[i] != Loss {
+					t.Errorf("playGame(): game %d finalized with %v for %c, want %v", i+1, w.values[i], w.sides[i].Byte(), Loss)
+				}
+			}
+
+			// The first engine plays gold in the first game and silver in the second.
+			score, err := m.Play(2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if score.Wins != 1 || score.Losses != 1 || score.Draws != 0 {
+				t.Errorf("Play(2): got %d - %d - %d, want 1 - 1 - 0", score.Wins, score.Losses, score.Draws)
+			}
+		})
+	}
+}
+
+func TestPlayMatchCommand(t *testing.T) {
+	engine := newTestEngine(t, 5)
+	var out bytes.Buffer
+	engine.log = log.New(&out, "log ", 0)
+	engine.out = log.New(&bytes.Buffer{}, "", 0)
+	engine.debug = log.New(&bytes.Buffer{}, "", 0)
+	if err := engine.ExecuteCommand("playmatch 2 playouts=10"); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); !strings.Contains(got, "log candidate ") || !strings.Contains(got, " draws elo ") {
+		t.Errorf("playmatch: got output %q, want candidate score", got)
+	}
+	for _, args := range []string{"", "x", "2 playouts"} {
+		if err := engine.ExecuteCommand("playmatch " + args); err == nil {
+			t.Errorf("playmatch %s: got nil error, want error", args)
+		}
+	}
+}
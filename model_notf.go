@@ -0,0 +1,25 @@
+//go:build !tensorflow
+
+package zoo
+
+import "fmt"
+
+// Model is unavailable when built without the tensorflow build tag.
+type Model struct{}
+
+// NewModel returns an error since the engine was built without Tensorflow.
+// Build with -tags tensorflow or use the pure Go ResNet instead.
+func NewModel(graphPath string) (*Model, error) {
+	return nil, fmt.Errorf("built without tensorflow support: rebuild with -tags tensorflow or use -resnet_weights_path")
+}
+
+// EvaluateBatch is provided for ModelInterface.
+func (m *Model) EvaluateBatch(ps []*Pos) (values []float32, policies [][]float32) {
+	panic("built without tensorflow support")
+}
+
+// SetSeed is provided for ModelInterface.
+func (m *Model) SetSeed(seed int64) {}
+
+// Close is provided for ModelInterface.
+func (m *Model) Close() error { return nil }
@@ -0,0 +1,99 @@
+//go:build tensorflow
+
+package zoo
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+)
+
+// Model wraps a Tensorflow SavedModel.
+type Model struct {
+	g      *tf.Graph
+	sess   *tf.Session
+	input  tf.Output
+	policy tf.Output
+	value  tf.Output
+}
+
+// NewModel loads the saved_model.pb from the saved_models directory or returns an error.
+func NewModel(graphPath string) (*Model, error) {
+	bs, err := ioutil.ReadFile(graphPath)
+	if err != nil {
+		return nil, err
+	}
+	g := tf.NewGraph()
+	if err := g.Import(bs, ""); err != nil {
+		return nil, err
+	}
+	opInput := g.Operation(modelInputName)
+	if opInput == nil {
+		return nil, fmt.Errorf("missing input operation %q", modelInputName)
+	}
+	opPolicy := g.Operation(policyOutputName)
+	if opPolicy == nil {
+		return nil, fmt.Errorf("missing policy operation %q", policyOutputName)
+	}
+	opValue := g.Operation(valueOutputName)
+	if opValue == nil {
+		return nil, fmt.Errorf("missing value operation %q", valueOutputName)
+	}
+	sess, err := tf.NewSession(g, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Model{
+		g:      g,
+		sess:   sess,
+		input:  opInput.Output(0),
+		policy: opPolicy.Output(0),
+		value:  opValue.Output(0),
+	}, nil
+}
+
+// Horribly named nodes, not for lack of trying.
+const (
+	modelInputName   = "x"
+	valueOutputName  = "bot_alpha_zoo-16/value_/dense_1/Tanh"
+	policyOutputName = "bot_alpha_zoo-16/policy_/dense_3/BiasAdd"
+)
+
+// EvaluateBatch runs the model on the batch of positions.
+// Sessions are safe for concurrent use so no locking is needed.
+func (m *Model) EvaluateBatch(ps []*Pos) (values []float32, policies [][]float32) {
+	if len(ps) == 0 {
+		return nil, nil
+	}
+	input := make([][][][]float32, len(ps))
+	for i, p := range ps {
+		input[i] = newDenseFeatures()
+		DenseFeatures(p, input[i])
+	}
+	tIn, err := tf.NewTensor(input)
+	if err != nil {
+		panic(err)
+	}
+	ts, err := m.sess.Run(map[tf.Output]*tf.Tensor{
+		m.input: tIn,
+	}, []tf.Output{m.policy, m.value}, nil)
+	if err != nil {
+		panic(err)
+	}
+	policies = ts[0].Value().([][]float32)
+	values = make([]float32, len(ps))
+	for i, v := range ts[1].Value().([][]float32) {
+		values[i] = v[0]
+	}
+	return values, policies
+}
+
+// SetSeed is a noop in the real model.
+// Provided for ModelInterface.
+func (m *Model) SetSeed(seed int64) {}
+
+// Close closes the model session.
+func (m *Model) Close() error {
+	return m.sess.Close()
+}
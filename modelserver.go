@@ -0,0 +1,331 @@
+package zoo
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+// The model server protocol is a sequence of request and response pairs on a stream connection.
+// Integers and floats are little endian.
+//
+// A request is a uint32 number of positions followed by the encoded positions.
+// Each position is 64 board bytes, the side, the steps left, the push flag, the push piece,
+// the push source square and a uint32 move number. Only the state needed for the network
+// input features is sent.
+//
+// A response starts with a uint32 error length. If it is not 0, the error message follows
+// and no position of the request was evaluated. Otherwise the response has a float32 value,
+// a uint32 policy length and the float32 policy logits for each position.
+const (
+	evalPosSize = 64 + 5 + 4
+
+	// maxEvalBatch limits the number of positions in a request.
+	maxEvalBatch = 1 << 16
+
+	// maxEvalError limits the length of an error message in a response.
+	maxEvalError = 1 << 10
+)
+
+// appendEvalPos appends the encoded position p to bs.
+func appendEvalPos(bs []byte, p *Pos) []byte {
+	for i := A1; i <= H8; i++ {
+		bs = append(bs, byte(p.At(i)))
+	}
+	src, piece, push := p.Push()
+	var pushByte byte
+	if push {
+		pushByte = 1
+	}
+	bs = append(bs, byte(p.side), byte(p.stepsLeft), pushByte, byte(piece), byte(src))
+	return binary.LittleEndian.AppendUint32(bs, uint32(p.moveNum))
+}
+
+// decodeEvalPos decodes a position from bs.
+// The position only has the state needed for the network input features (see newFeaturePos).
+func decodeEvalPos(bs []byte) (*Pos, error) {
+	if len(bs) < evalPosSize {
+		return nil, fmt.Errorf("short position: %d bytes", len(bs))
+	}
+	var board [64]Piece
+	for i := A1; i <= H8; i++ {
+		if piece := Piece(bs[i]); piece != Empty {
+			if !piece.Valid() {
+				return nil, fmt.Errorf("bad piece at %s: %d", i, piece)
+			}
+			board[i] = piece
+		}
+	}
+	bs = bs[64:]
+	side := Color(bs[0])
+	if side != Gold && side != Silver {
+		return nil, fmt.Errorf("bad side: %d", side)
+	}
+	moveNum := int(binary.LittleEndian.Uint32(bs[5:]))
+	// Setup takes up to 16 steps and other turns up to 4.
+	stepsLeft := int(bs[1])
+	if stepsLeft > 16 || moveNum != 1 && stepsLeft > 4 {
+		return nil, fmt.Errorf("bad steps left: %d", stepsLeft)
+	}
+	push := pushInfo{push: bs[2] != 0, piece: Piece(bs[3]), src: Square(bs[4])}
+	if push.piece != Empty && !push.piece.Valid() {
+		return nil, fmt.Errorf("bad push piece: %d", push.piece)
+	}
+	// Square 64 stands for no square when there is no push piece.
+	if push.src > 64 || push.src == 64 && push.piece != Empty {
+		return nil, fmt.Errorf("bad push square: %d", push.src)
+	}
+	if push.push && push.piece == Empty {
+		return nil, fmt.Errorf("push without a piece")
+	}
+	return newFeaturePos(&board, side, stepsLeft, push, moveNum), nil
+}
+
+// ModelServer serves a ModelInterface to ModelClients.
+// Positions from concurrent clients are evaluated together in batches.
+type ModelServer struct {
+	model *BatchEvaluator
+
+	m        sync.Mutex // guards fields below.
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+}
+
+// NewModelServer creates a server for model which collects positions into
+// batches of up to batchSize and waits at most batchTimeout for a batch to fill.
+func NewModelServer(model ModelInterface, batchSize int, batchTimeout time.Duration) *ModelServer {
+	return &ModelServer{
+		model: NewBatchEvaluator(model, batchSize, batchTimeout),
+		conns: make(map[net.Conn]struct{}),
+	}
+}
+
+// Serve accepts client connections on l until the server is closed.
+func (s *ModelServer) Serve(l net.Listener) error {
+	s.m.Lock()
+	if s.closed {
+		s.m.Unlock()
+		return fmt.Errorf("model server closed")
+	}
+	s.listener = l
+	s.m.Unlock()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.m.Lock()
+			closed := s.closed
+			s.m.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		s.m.Lock()
+		s.conns[conn] = struct{}{}
+		s.m.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+func (s *ModelServer) serveConn(conn net.Conn) {
+	defer func() {
+		s.m.Lock()
+		delete(s.conns, conn)
+		s.m.Unlock()
+		conn.Close()
+	}()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	var bs []byte
+	for {
+		var n uint32
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			if err != io.EOF {
+				s.logf("read request: %v", err)
+			}
+			return
+		}
+		if n > maxEvalBatch {
+			s.logf("request too large: %d positions", n)
+			return
+		}
+		if size := int(n) * evalPosSize; cap(bs) < size {
+			bs = make([]byte, size)
+		} else {
+			bs = bs[:size]
+		}
+		if _, err := io.ReadFull(r, bs); err != nil {
+			s.logf("read request: %v", err)
+			return
+		}
+		ps := make([]*Pos, n)
+		var err error
+		for i := range ps {
+			if ps[i], err = decodeEvalPos(bs[i*evalPosSize:]); err != nil {
+				err = fmt.Errorf("decode position %d: %v", i, err)
+				break
+			}
+		}
+		out := bs[:0]
+		if err != nil {
+			s.logf("%v", err)
+			msg := err.Error()
+			if len(msg) > maxEvalError {
+				msg = msg[:maxEvalError]
+			}
+			out = binary.LittleEndian.AppendUint32(out, uint32(len(msg)))
+			out = append(out, msg...)
+		} else {
+			out = s.appendEvals(out, ps)
+		}
+		bs = out
+		if _, err := w.Write(out); err != nil {
+			s.logf("write response: %v", err)
+			return
+		}
+		if err := w.Flush(); err != nil {
+			s.logf("write response: %v", err)
+			return
+		}
+	}
+}
+
+// appendEvals evaluates ps and appends the response to out.
+func (s *ModelServer) appendEvals(out []byte, ps []*Pos) []byte {
+	values, policies := s.model.EvaluateBatch(ps)
+	out = binary.LittleEndian.AppendUint32(out, 0)
+	for i := range ps {
+		out = binary.LittleEndian.AppendUint32(out, math.Float32bits(values[i]))
+		out = binary.LittleEndian.AppendUint32(out, uint32(len(policies[i])))
+		for _, v := range policies[i] {
+			out = binary.LittleEndian.AppendUint32(out, math.Float32bits(v))
+		}
+	}
+	return out
+}
+
+// logf logs connection errors unless the server was closed.
+func (s *ModelServer) logf(format string, v ...interface{}) {
+	s.m.Lock()
+	closed := s.closed
+	s.m.Unlock()
+	if !closed {
+		log.Printf("model server: "+format, v...)
+	}
+}
+
+// Close stops accepting connections and closes all client connections.
+// The underlying model is not closed.
+func (s *ModelServer) Close() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	return err
+}
+
+// ModelClient implements ModelInterface by sending positions to a ModelServer.
+// Requests on one client are sent one at a time.
+type ModelClient struct {
+	m    sync.Mutex // guards conn, r and w.
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+	buf  []byte
+}
+
+// DialModelServer connects to the model server listening on the Unix socket at path.
+func DialModelServer(path string) (*ModelClient, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return NewModelClient(conn), nil
+}
+
+// NewModelClient creates a client which talks to a model server on conn.
+func NewModelClient(conn net.Conn) *ModelClient {
+	return &ModelClient{
+		conn: conn,
+		r:    bufio.NewReader(conn),
+		w:    bufio.NewWriter(conn),
+	}
+}
+
+// EvaluateBatch sends the positions to the server and waits for the evaluations.
+// Like Model, EvaluateBatch panics if the server cannot be reached.
+func (c *ModelClient) EvaluateBatch(ps []*Pos) (values []float32, policies [][]float32) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	values, policies, err := c.evaluateBatch(ps)
+	if err != nil {
+		panic(fmt.Errorf("model client: %v", err))
+	}
+	return values, policies
+}
+
+func (c *ModelClient) evaluateBatch(ps []*Pos) (values []float32, policies [][]float32, err error) {
+	bs := binary.LittleEndian.AppendUint32(c.buf[:0], uint32(len(ps)))
+	for _, p := range ps {
+		bs = appendEvalPos(bs, p)
+	}
+	c.buf = bs
+	if _, err := c.w.Write(bs); err != nil {
+		return nil, nil, err
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, nil, err
+	}
+	var errLen uint32
+	if err := binary.Read(c.r, binary.LittleEndian, &errLen); err != nil {
+		return nil, nil, err
+	}
+	if errLen > maxEvalError {
+		return nil, nil, fmt.Errorf("bad error size: %d", errLen)
+	}
+	if errLen > 0 {
+		msg := make([]byte, errLen)
+		if _, err := io.ReadFull(c.r, msg); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("server: %s", msg)
+	}
+	values = make([]float32, len(ps))
+	policies = make([][]float32, len(ps))
+	for i := range ps {
+		var header [2]uint32
+		if err := binary.Read(c.r, binary.LittleEndian, &header); err != nil {
+			return nil, nil, err
+		}
+		values[i] = math.Float32frombits(header[0])
+		if header[1] > modelOutputPolicySize {
+			return nil, nil, fmt.Errorf("bad policy size: %d", header[1])
+		}
+		policies[i] = make([]float32, header[1])
+		if err := binary.Read(c.r, binary.LittleEndian, policies[i]); err != nil {
+			return nil, nil, err
+		}
+	}
+	return values, policies, nil
+}
+
+// SetSeed is a noop for the client since the server model is shared.
+func (c *ModelClient) SetSeed(seed int64) {}
+
+// Close closes the connection to the server.
+func (c *ModelClient) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,263 @@
+package zoo
+
+import (
+	"bytes"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEvalPosRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		shortPosition string
+		setup         bool
+		steps         []Step
+	}{{
+		name:          "opening",
+		shortPosition: "s [rrrrrrrrhdcemcdh                                HDCMECDHRRRRRRRR]",
+	}, {
+		name:          "setup",
+		shortPosition: "g [                                                                ]",
+		setup:         true,
+		steps:         []Step{MakeSetup(GElephant, E2)},
+	}, {
+		name:          "in push",
+		shortPosition: "g [                                  r       Cr      D             ]",
+		steps:         []Step{MakeStep(SRabbit, D3, D4)},
+	}, {
+		name:          "last step",
+		shortPosition: "g [       r                            Ed     Cr           R       ]",
+		steps: []Step{
+			MakeStep(GCat, D3, D2),
+			MakeStep(SRabbit, E3, D3),
+			MakeStep(SRabbit, D3, E3),
+		},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := ParseShortPosition(tc.shortPosition)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.setup {
+				p = NewEmptyPosition()
+			}
+			for _, step := range tc.steps {
+				p.Step(step)
+			}
+			got, err := decodeEvalPos(appendEvalPos(nil, p))
+			if err != nil {
+				t.Fatalf("decodeEvalPos(): %v", err)
+			}
+			want := newDenseFeatures()
+			DenseFeatures(p, want)
+			gotInput := newDenseFeatures()
+			DenseFeatures(got, gotInput)
+			for i := 0; i < 64; i++ {
+				for k := 0; k < featurePlanes; k++ {
+					if g, w := gotInput[i/8][i%8][k], want[i/8][i%8][k]; g != w {
+						t.Errorf("decodeEvalPos(): plane %d at %s: got %v, want %v", k, Square(i), g, w)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestEvalPosGoal checks that the server builds the goal plane of the client for a position
+// where Pos.Goal depends on the repetition history which is not sent.
+func TestEvalPosGoal(t *testing.T) {
+	p, err := ParseShortPosition("g [                R                                   e       E   ]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The position after the one step goal Ra7n already occurred twice,
+	// so Pos.Goal needs another step to avoid a third repetition.
+	q := p.Clone()
+	q.Step(MakeStep(GRabbit, A6, A7))
+	q.Step(MakeStep(GRabbit, A7, A8))
+	h := q.hashAfterPass()
+	p.resetHistory([]Hash{h, h})
+	p.Step(MakeStep(GRabbit, A6, A7))
+	if m, ok := p.Goal(); !ok || m.Len() != 2 {
+		t.Fatalf("Goal(): got %s, %v, want a 2 step goal", m, ok)
+	}
+
+	got, err := decodeEvalPos(appendEvalPos(nil, p))
+	if err != nil {
+		t.Fatalf("decodeEvalPos(): %v", err)
+	}
+	want := newDenseFeatures()
+	DenseFeatures(p, want)
+	gotInput := newDenseFeatures()
+	DenseFeatures(got, gotInput)
+	for i := 0; i < 64; i++ {
+		for k := 0; k < featurePlanes; k++ {
+			if g, w := gotInput[i/8][i%8][k], want[i/8][i%8][k]; g != w {
+				t.Errorf("decodeEvalPos(): plane %d at %s: got %v, want %v", k, Square(i), g, w)
+			}
+		}
+	}
+	// The goal threat ignores the history and steps to A8 directly.
+	if want[7][0][goalPlane] != 1 {
+		t.Errorf("DenseFeatures(): got no goal threat at a8, want 1")
+	}
+}
+
+func TestDecodeEvalPosErrors(t *testing.T) {
+	good := appendEvalPos(nil, NewEmptyPosition())
+	// corrupt returns good with the byte at i set to b.
+	corrupt := func(i int, b byte) []byte {
+		bs := append([]byte(nil), good...)
+		bs[i] = b
+		return bs
+	}
+	afterSetup := corrupt(69, 2)
+	afterSetup[65] = 5
+	pushSquare := corrupt(67, byte(GDog))
+	pushSquare[68] = 64
+	for _, tc := range []struct {
+		name string
+		bs   []byte
+	}{
+		{"short", good[:10]},
+		{"bad piece", corrupt(0, 7)},
+		{"bad side", corrupt(64, 2)},
+		{"bad steps left", corrupt(65, 17)},
+		{"bad steps left after setup", afterSetup},
+		{"bad push piece", corrupt(67, 7)},
+		{"bad push square", corrupt(68, 65)},
+		{"no push square", pushSquare},
+		{"push without a piece", corrupt(66, 1)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := decodeEvalPos(tc.bs); err == nil {
+				t.Errorf("decodeEvalPos(): got nil error, want error")
+			}
+		})
+	}
+}
+
+func TestModelServerCorruptRequest(t *testing.T) {
+	path := startModelServer(t, &countingModel{}, 1, time.Millisecond)
+	c, err := DialModelServer(path)
+	if err != nil {
+		t.Fatalf("DialModelServer(): %v", err)
+	}
+	defer c.Close()
+
+	bad := NewEmptyPosition()
+	bad.moveNum = 2
+	bad.stepsLeft = 9
+	if _, _, err := c.evaluateBatch([]*Pos{bad}); err == nil || !strings.Contains(err.Error(), "bad steps left") {
+		t.Fatalf("evaluateBatch(): got error %v, want bad steps left", err)
+	}
+	// The connection is still usable after an error reply.
+	good := NewEmptyPosition()
+	values, _, err := c.evaluateBatch([]*Pos{good})
+	if err != nil {
+		t.Fatalf("evaluateBatch(): %v", err)
+	}
+	if got, want := values[0], float32(good.moveNum); got != want {
+		t.Errorf("evaluateBatch(): got value %v, want %v", got, want)
+	}
+}
+
+// startModelServer serves model on a Unix socket in a temporary directory and returns the socket path.
+func startModelServer(t *testing.T, model ModelInterface, batchSize int, batchTimeout time.Duration) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "model.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewModelServer(model, batchSize, batchTimeout)
+	done := make(chan error)
+	go func() { done <- s.Serve(l) }()
+	t.Cleanup(func() {
+		s.Close()
+		if err := <-done; err != nil {
+			t.Errorf("Serve(): %v", err)
+		}
+	})
+	return path
+}
+
+func TestModelServer(t *testing.T) {
+	model, err := ReadResNet(bytes.NewReader(writeWeights(randomResNetWeights(1, 1, 4))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := startModelServer(t, model, 4, time.Millisecond)
+
+	p, err := ParseShortPosition("g [rrrrrrrrhdcemcdh                                HDCMECDHRRRRRRRR]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := p.Clone()
+	q.Step(MakeStep(GRabbit, A2, A3))
+	ps := []*Pos{p, q}
+	wantValues, wantPolicies := model.EvaluateBatch(ps)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		c, err := DialModelServer(path)
+		if err != nil {
+			t.Fatalf("DialModelServer(): %v", err)
+		}
+		defer c.Close()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			values, policies := c.EvaluateBatch(ps)
+			for i := range ps {
+				if values[i] != wantValues[i] {
+					t.Errorf("EvaluateBatch(): got value %v, want %v", values[i], wantValues[i])
+				}
+				if len(policies[i]) != len(wantPolicies[i]) {
+					t.Errorf("EvaluateBatch(): got policy size %d, want %d", len(policies[i]), len(wantPolicies[i]))
+					continue
+				}
+				for j := range policies[i] {
+					if policies[i][j] != wantPolicies[i][j] {
+						t.Errorf("EvaluateBatch(): got policy[%d] %v, want %v", j, policies[i][j], wantPolicies[i][j])
+						break
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestModelServerBatching(t *testing.T) {
+	model := &countingModel{}
+	path := startModelServer(t, model, 3, time.Hour)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		c, err := DialModelServer(path)
+		if err != nil {
+			t.Fatalf("DialModelServer(): %v", err)
+		}
+		defer c.Close()
+		p := NewEmptyPosition()
+		p.moveNum = i + 1
+		p.stepsLeft = 4
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			values, _ := c.EvaluateBatch([]*Pos{p})
+			if got, want := values[0], float32(p.moveNum); got != want {
+				t.Errorf("EvaluateBatch(): got value %v, want %v", got, want)
+			}
+		}()
+	}
+	wg.Wait()
+	if len(model.batches) != 1 || model.batches[0] != 3 {
+		t.Errorf("EvaluateBatch(): got batches %v, want [3]", model.batches)
+	}
+}
@@ -73,11 +73,16 @@ func (p *Pos) generateSteps(a *[]ExtStep) {
 		p.generateSetupSteps(a)
 		return
 	}
+	p.generateStepsFrom(a, ^Bitboard(0))
+}
+
+// generateStepsFrom appends the steps of pieces on from to a outside of setup.
+func (p *Pos) generateStepsFrom(a *[]ExtStep, from Bitboard) {
 	ourSide := p.Side()
 	ourRabbit := GRabbit.WithColor(ourSide)
 	empty := p.Empty()
 	occupied := ^empty
-	for b := occupied; b > 0; b &= b - 1 {
+	for b := occupied & from; b > 0; b &= b - 1 {
 		src := b.Square()
 		t := p.At(src)
 
@@ -129,3 +134,94 @@ func (p *Pos) generateSetupSteps(a *[]ExtStep) {
 		(*a)[l].Step = MakeSetup(Piece(t).WithColor(c), i)
 	}
 }
+
+// turnKey identifies a position within a turn.
+// The push state is included because it affects which steps are legal.
+type turnKey struct {
+	hash  Hash
+	src   Square
+	piece Piece
+	push  bool
+}
+
+func (p *Pos) turnKey() turnKey {
+	src, piece, push := p.Push()
+	return turnKey{p.Hash(), src, piece, push}
+}
+
+// GenerateMoves returns every distinct legal way to complete the current turn.
+// Moves include pushes, pulls and capture steps. Moves which reach the same
+// position are deduplicated by Hash, keeping the shortest found. An empty move is
+// included when the turn can be passed now. No moves are generated during setup.
+func (p *Pos) GenerateMoves() []Move {
+	if p.moveNum == 1 || p.stepsLeft == 0 {
+		return nil
+	}
+	g := moveGen{
+		p:    p.Clone(),
+		c:    p.Side(),
+		seen: make(map[turnKey]bool),
+		ends: make(map[Hash]int),
+	}
+	g.generate()
+	return g.moves
+}
+
+// moveGen is the state of a full turn move generation.
+type moveGen struct {
+	p     *Pos
+	c     Color            // side to move.
+	m     Move             // steps taken so far.
+	seen  map[turnKey]bool // positions within the turn already generated from.
+	ends  map[Hash]int     // index of the move by position at the end of the turn.
+	moves []Move
+}
+
+// generate appends the moves from the current position in the turn.
+func (g *moveGen) generate() {
+	p := g.p
+	if p.Side() != g.c {
+		// The last step ended the turn.
+		g.add(p.Hash())
+		return
+	}
+	key := p.turnKey()
+	if g.seen[key] {
+		return
+	}
+	g.seen[key] = true
+	if p.CanPass() {
+		p.Pass()
+		g.add(p.Hash())
+		p.Unpass()
+	}
+	var steps []ExtStep
+	p.generateSteps(&steps)
+	for _, s := range steps {
+		if !p.Legal(s.Step) {
+			continue
+		}
+		n := len(g.m)
+		g.m = append(g.m, s.Step)
+		if cap := p.Step(s.Step); cap.Capture() {
+			g.m = append(g.m, cap)
+		}
+		g.generate()
+		g.m = g.m[:n]
+		p.Unstep()
+	}
+}
+
+// add adds the current move ending at the position with hash h.
+// It replaces a longer move reaching the same position.
+func (g *moveGen) add(h Hash) {
+	i, ok := g.ends[h]
+	if !ok {
+		g.ends[h] = len(g.moves)
+		g.moves = append(g.moves, append(Move(nil), g.m...))
+		return
+	}
+	if g.m.Len() < g.moves[i].Len() {
+		g.moves[i] = append(Move(nil), g.m...)
+	}
+}
@@ -0,0 +1,81 @@
+package zoo
+
+import "testing"
+
+func TestGenerateMoves(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		shortPosition string
+		steps         []Step
+		want          int      // number of distinct moves.
+		contains      []string // moves which are generated.
+	}{{
+		name:          "lone rabbit",
+		shortPosition: "g [                                                        R       ]",
+		want:          14, // 13 squares and capture on c3.
+		contains:      []string{"Ra1n", "Ra1e Rb1e Rc1e Rd1e", "Ra1e Rb1e Rc1n Rc2n Rc3x"},
+	}, {
+		name:          "rest of the turn",
+		shortPosition: "g [                                                        R       ]",
+		steps:         []Step{MakeStep(GRabbit, A1, A2)},
+		want:          10, // pass, 8 squares and capture on c3.
+		contains:      []string{"", "Ra2n"},
+	}, {
+		name:          "frozen",
+		shortPosition: "g [                                                        Rc      ]",
+	}, {
+		name:          "push and pull",
+		shortPosition: "g [       r                   r       E                            ]",
+		contains:      []string{"rd5n Ed4n", "Ed4s rd5s", "Ed4s rd5s Ed3w Ec3x"},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := ParseShortPosition(tc.shortPosition)
+			if err != nil {
+				t.Fatalf("ParseShortPosition(%q): %v", tc.shortPosition, err)
+			}
+			for _, step := range tc.steps {
+				if !p.Legal(step) {
+					t.Fatalf("Intermediate step is not legal: %s", step)
+				}
+				p.Step(step)
+			}
+			moves := p.GenerateMoves()
+			if tc.want != 0 || tc.contains == nil {
+				if got := len(moves); got != tc.want {
+					t.Errorf("GenerateMoves(): got %d moves %v, want %d", got, moves, tc.want)
+				}
+			}
+
+			generated := make(map[string]bool)
+			ends := make(map[Hash]Move)
+			for _, m := range moves {
+				generated[m.String()] = true
+				q := p.Clone()
+				for _, s := range m {
+					if s.Capture() {
+						continue
+					}
+					if !q.Legal(s) {
+						t.Fatalf("GenerateMoves(): got move %s with illegal step %s", m, s)
+					}
+					q.Step(s)
+				}
+				if q.Side() == p.Side() {
+					if !q.CanPass() {
+						t.Fatalf("GenerateMoves(): got move %s which cannot end the turn", m)
+					}
+					q.Pass()
+				}
+				if other, ok := ends[q.Hash()]; ok {
+					t.Errorf("GenerateMoves(): got moves %s and %s with the same position", other, m)
+				}
+				ends[q.Hash()] = m
+			}
+			for _, m := range tc.contains {
+				if !generated[m] {
+					t.Errorf("GenerateMoves(): got moves %v, want %q", moves, m)
+				}
+			}
+		})
+	}
+}
@@ -15,6 +15,7 @@ var turnPattern = regexp.MustCompile(`^(\d+)([gswb])`)
 
 // ParseMoveList reads a move list from the string s or returns an error.
 // The movelist always starts at 1g including setup moves.
+// Moves are replayed and returned in canonical notation (see MoveList.Normalize).
 func ParseMoveList(s string) (MoveList, error) {
 	var (
 		sc          = bufio.NewScanner(strings.NewReader(s))
@@ -58,6 +59,27 @@ func ParseMoveList(s string) (MoveList, error) {
 			turnNumber++
 		}
 	}
+	return res.Normalize()
+}
+
+// Normalize replays the moves of l from the initial position and returns them in
+// canonical notation (see Pos.NormalizeMove). An empty last move is kept as the
+// move in progress. An error is returned for the first illegal move.
+func (l MoveList) Normalize() (MoveList, error) {
+	p := NewEmptyPosition()
+	res := make(MoveList, 0, len(l))
+	for i, m := range l {
+		if len(m) == 0 && i == len(l)-1 {
+			res = append(res, m)
+			break
+		}
+		n, err := p.NormalizeMove(m)
+		if err != nil {
+			return nil, fmt.Errorf("%d%c: %w", p.MoveNum(), p.Side().Byte(), err)
+		}
+		p.Move(n)
+		res = append(res, n)
+	}
 	return res, nil
 }
 
@@ -0,0 +1,83 @@
+package zoo
+
+import (
+	"math"
+	"math/rand"
+)
+
+// sampleGamma returns a sample from the Gamma(alpha, 1) distribution
+// using the method of Marsaglia and Tsang.
+func sampleGamma(r *rand.Rand, alpha float64) float64 {
+	if alpha < 1 {
+		// Boost alpha and correct with a uniform power.
+		return sampleGamma(r, alpha+1) * math.Pow(r.Float64(), 1/alpha)
+	}
+	d := alpha - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := r.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := r.Float64()
+		if u < 1-0.0331*x*x*x*x || math.Log(u) < 0.5*x*x+d*(1-v+math.Log(v)) {
+			return d * v
+		}
+	}
+}
+
+// sampleDirichlet fills x with a sample from the symmetric Dirichlet distribution with parameter alpha.
+func sampleDirichlet(r *rand.Rand, alpha float64, x []float64) {
+	var sum float64
+	for i := range x {
+		x[i] = sampleGamma(r, alpha)
+		sum += x[i]
+	}
+	if sum == 0 {
+		// All samples underflowed.
+		for i := range x {
+			x[i] = 1 / float64(len(x))
+		}
+		return
+	}
+	for i := range x {
+		x[i] /= sum
+	}
+}
+
+// sampleTemperature returns an index sampled with probability proportional to runs^(1/temperature).
+func sampleTemperature(r *rand.Rand, runs []uint32, temperature float64) int {
+	max := math.Inf(-1)
+	logits := make([]float64, len(runs))
+	for i, n := range runs {
+		logits[i] = math.Log(float64(n)) / temperature
+		if logits[i] > max {
+			max = logits[i]
+		}
+	}
+	if math.IsInf(max, -1) {
+		// No runs.
+		return r.Intn(len(runs))
+	}
+	var sum float64
+	for i, l := range logits {
+		logits[i] = math.Exp(l - max)
+		sum += logits[i]
+	}
+	x := r.Float64() * sum
+	last := 0
+	for i, w := range logits {
+		if w == 0 {
+			continue
+		}
+		if x < w {
+			return i
+		}
+		x -= w
+		last = i
+	}
+	// Rounding error.
+	return last
+}
@@ -0,0 +1,168 @@
+package zoo
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestSampleGamma(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, alpha := range []float64{0.03, 0.3, 1, 2.5} {
+		const n = 20000
+		var sum float64
+		for i := 0; i < n; i++ {
+			x := sampleGamma(r, alpha)
+			if x < 0 {
+				t.Fatalf("sampleGamma(%v): got negative sample %v", alpha, x)
+			}
+			sum += x
+		}
+		// The mean of Gamma(alpha, 1) is alpha.
+		if mean := sum / n; math.Abs(mean-alpha) > 0.1*alpha {
+			t.Errorf("sampleGamma(%v): got mean %v, want %v", alpha, mean, alpha)
+		}
+	}
+}
+
+func TestSampleDirichlet(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	x := make([]float64, 20)
+	for i := 0; i < 100; i++ {
+		sampleDirichlet(r, 0.3, x)
+		var sum float64
+		for _, v := range x {
+			if v < 0 {
+				t.Fatalf("sampleDirichlet(): got negative component %v", v)
+			}
+			sum += v
+		}
+		if math.Abs(sum-1) > 1e-9 {
+			t.Fatalf("sampleDirichlet(): got sum %v, want 1", sum)
+		}
+	}
+}
+
+func TestSampleTemperature(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, tc := range []struct {
+		name        string
+		runs        []uint32
+		temperature float64
+		want        []float64 // expected frequencies.
+	}{{
+		name:        "proportional",
+		runs:        []uint32{30, 10, 0, 60},
+		temperature: 1,
+		want:        []float64{0.3, 0.1, 0, 0.6},
+	}, {
+		name:        "sharpened",
+		runs:        []uint32{1, 2},
+		temperature: 0.5,
+		want:        []float64{0.2, 0.8},
+	}, {
+		name:        "flattened",
+		runs:        []uint32{1, 4},
+		temperature: 2,
+		want:        []float64{1. / 3, 2. / 3},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			const n = 20000
+			counts := make([]float64, len(tc.runs))
+			for i := 0; i < n; i++ {
+				counts[sampleTemperature(r, tc.runs, tc.temperature)]++
+			}
+			for i := range counts {
+				if got := counts[i] / n; math.Abs(got-tc.want[i]) > 0.02 {
+					t.Errorf("sampleTemperature(): got frequency %v for %d, want %v", got, i, tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAddRootNoise(t *testing.T) {
+	engine := newTestEngine(t, 10)
+	engine.GoWait()
+	tree := engine.tree
+	before := make([]float32, len(tree.root.children))
+	for i, c := range tree.root.children {
+		before[i] = c.prior
+	}
+
+	tree.AddRootNoise(rand.New(rand.NewSource(1)), 0.3, 0.25)
+	var sum float64
+	changed := false
+	for i, c := range tree.root.children {
+		sum += float64(c.prior)
+		changed = changed || c.prior != before[i]
+		if min := 0.75 * before[i]; c.prior < min-1e-6 {
+			t.Errorf("AddRootNoise(): got prior %v, want at least %v", c.prior, min)
+		}
+	}
+	if !changed {
+		t.Errorf("AddRootNoise(): priors did not change")
+	}
+	if math.Abs(sum-1) > 1e-4 {
+		t.Errorf("AddRootNoise(): got prior sum %v, want 1", sum)
+	}
+
+	// Noise is only added once for the same root.
+	noised := make([]float32, len(tree.root.children))
+	for i, c := range tree.root.children {
+		noised[i] = c.prior
+	}
+	tree.AddRootNoise(rand.New(rand.NewSource(2)), 0.3, 0.25)
+	for i, c := range tree.root.children {
+		if c.prior != noised[i] {
+			t.Fatalf("AddRootNoise(): added noise twice to the same root")
+		}
+	}
+}
+
+func TestMoveTemperature(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		sample  bool
+		moveNum int
+		want    float64
+	}{
+		{"not sampling", false, 1, 0},
+		{"first move", true, 1, 1},
+		{"decaying", true, 6, 0.5},
+		{"decayed", true, 11, 0},
+		{"late", true, 40, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tree := NewEmptyTree(nil)
+			tree.p = NewEmptyPosition()
+			tree.p.moveNum = tc.moveNum
+			tree.SetSample(tc.sample)
+			tree.SetTemperature(1, 10)
+			if got := tree.moveTemperature(); math.Max(got, 0) != tc.want {
+				t.Errorf("moveTemperature(): got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSelfPlaySetup(t *testing.T) {
+	engine := newTestEngine(t, 50)
+	engine.NewGame()
+	engine.timeInfo = nil
+	engine.selfPlay = true
+	engine.GoWait()
+	m := engine.bestMove
+	if got := m.Len(); got != 16 {
+		t.Fatalf("GoWait(): got setup move %s with %d steps, want 16", m, got)
+	}
+	for _, s := range m {
+		if !s.Setup() {
+			t.Errorf("GoWait(): got step %s in setup move, want setup steps", s)
+		}
+	}
+	engine.MakeMove(m)
+	if engine.Side() != Silver || engine.MoveNum() != 1 {
+		t.Errorf("MakeMove(%s): got %c to move at move %d, want silver at move 1", m, engine.Side().Byte(), engine.MoveNum())
+	}
+}
@@ -0,0 +1,66 @@
+package zoo
+
+import (
+	"fmt"
+	"sort"
+)
+
+// NormalizeMove returns m in canonical notation as played from p.
+// Capture steps in m are dropped and the captures which result from playing m are
+// inserted after the steps which cause them. Setup steps are ordered by square.
+// A *MoveError is returned if m does not complete the current turn legally.
+// p is not modified.
+func (p *Pos) NormalizeMove(m Move) (Move, error) {
+	res, err := p.replayMove(m, false)
+	if err != nil {
+		return nil, err
+	}
+	if p.moveNum == 1 {
+		sort.Slice(res, func(i, j int) bool { return res[i].Dest() < res[j].Dest() })
+	}
+	return res, nil
+}
+
+// MoveTo returns the move in canonical notation which completes the current turn of p
+// and arrives at the board of q with the opponent to move. The shortest such move is
+// returned. An error is returned if no legal move arrives at q. p and q are not modified.
+func (p *Pos) MoveTo(q *Pos) (Move, error) {
+	if q.Side() == p.Side() {
+		return nil, fmt.Errorf("want %c to move after the move: %s", p.Side().Opposite().Byte(), q.ShortString())
+	}
+	want := q.boardString()
+	if p.moveNum == 1 {
+		// The setup move places the pieces of the side to move which are not on p.
+		c := p.Side()
+		var m Move
+		for i := A1; i <= H8; i++ {
+			if t := q.At(i); t != Empty && t.Color() == c && p.At(i) == Empty {
+				m = append(m, MakeSetup(t, i))
+			}
+		}
+		r := p.Clone()
+		m, err := r.NormalizeMove(m)
+		if err != nil {
+			return nil, err
+		}
+		if r.Move(m); r.boardString() != want {
+			return nil, fmt.Errorf("no setup move arrives at %s", q.ShortString())
+		}
+		return m, nil
+	}
+	r := p.Clone()
+	var best Move
+	for _, m := range r.GenerateMoves() {
+		if best != nil && m.Len() >= best.Len() {
+			continue
+		}
+		if r.Move(m); r.boardString() == want {
+			best = m
+		}
+		r.undoMove(m)
+	}
+	if best == nil {
+		return nil, fmt.Errorf("no legal move arrives at %s", q.ShortString())
+	}
+	return best, nil
+}
@@ -0,0 +1,140 @@
+package zoo
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeMove(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		move string
+		want string
+		err  error
+	}{
+		{"canonical", "Eh6s Eh5w", "Eh6s Eh5w", nil},
+		{"missing capture", "cg6w Eh6w", "cg6w cf6x Eh6w", nil},
+		{"misplaced capture", "cg6w Eh6w cf6x", "cg6w cf6x Eh6w", nil},
+		{"wrong capture", "cg6w cg6x Eh6w", "cg6w cf6x Eh6w", nil},
+		{"illegal", "Ra2n", "", ErrFrozen},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := ParseShortPosition(tacticsPosition)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := ParseMove(tc.move)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := p.NormalizeMove(m)
+			if !errors.Is(err, tc.err) || (err == nil) != (tc.err == nil) {
+				t.Fatalf("NormalizeMove(%s): got error %v, want %v", tc.move, err, tc.err)
+			}
+			if got.String() != tc.want {
+				t.Errorf("NormalizeMove(%s): got %q, want %q", tc.move, got, tc.want)
+			}
+			if err == nil {
+				if err := p.ValidateMove(got); err != nil {
+					t.Errorf("ValidateMove(%s): %v", got, err)
+				}
+			}
+		})
+	}
+}
+
+func TestNormalizeSetup(t *testing.T) {
+	p := NewEmptyPosition()
+	m, err := ParseMove("Hh2 Ra1 Rb1 Rc1 Rd1 Re1 Rf1 Rg1 Rh1 Ha2 Db2 Cc2 Md2 Ee2 Cf2 Dg2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := p.NormalizeMove(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Ra1 Rb1 Rc1 Rd1 Re1 Rf1 Rg1 Rh1 Ha2 Db2 Cc2 Md2 Ee2 Cf2 Dg2 Hh2"; got.String() != want {
+		t.Errorf("NormalizeMove(%s): got %q, want %q", m, got, want)
+	}
+}
+
+func TestMoveTo(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		pos  string
+		move string
+		want string
+	}{
+		{"step", tacticsPosition, "Rh4n", "Rh4n"},
+		{"capture", tacticsPosition, "cg6w cf6x Eh6w", "cg6w cf6x Eh6w"},
+		{"shortest", tacticsPosition, "Eh6s Eh5n Rh4w", "Rh4w"},
+		{"setup", "", "Hh2 Ra1 Rb1 Rc1 Rd1 Re1 Rf1 Rg1 Rh1 Ha2 Db2 Cc2 Md2 Ee2 Cf2 Dg2",
+			"Ra1 Rb1 Rc1 Rd1 Re1 Rf1 Rg1 Rh1 Ha2 Db2 Cc2 Md2 Ee2 Cf2 Dg2 Hh2"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := NewEmptyPosition()
+			if tc.pos != "" {
+				var err error
+				if p, err = ParseShortPosition(tc.pos); err != nil {
+					t.Fatal(err)
+				}
+			}
+			m, err := ParseMove(tc.move)
+			if err != nil {
+				t.Fatal(err)
+			}
+			q := p.Clone()
+			q.Move(m)
+			got, err := p.MoveTo(q)
+			if err != nil {
+				t.Fatalf("MoveTo(%s): %v", q.ShortString(), err)
+			}
+			if got.String() != tc.want {
+				t.Errorf("MoveTo(%s): got %q, want %q", q.ShortString(), got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMoveToUnreachable(t *testing.T) {
+	p, err := ParseShortPosition(tacticsPosition)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := ParseShortPosition("s [           h    c  D  cEE              R        R               ]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, err := p.MoveTo(q); err == nil {
+		t.Errorf("MoveTo(%s): got move %s, want error", q.ShortString(), m)
+	}
+	if m, err := p.MoveTo(p); err == nil {
+		t.Errorf("MoveTo(%s): got move %s, want error", p.ShortString(), m)
+	}
+}
+
+func TestParseMoveListNormalize(t *testing.T) {
+	const list = `1g Ra1 Rb1 Rc1 Rd1 Re1 Rf1 Rg1 Rh1 Ha2 Db2 Cc2 Md2 Ee2 Cf2 Dg2 Hh2
+1s ra8 rb8 rc8 rd8 re8 rf8 rg8 rh8 ha7 db7 cc7 ed7 me7 cf7 dg7 hh7
+2g Ee2n Ee3n Ee4n Ee5n
+2s hh7s hh6s hh5s hh4s hh3x
+3g Ee6e`
+	l, err := ParseMoveList(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `1g Ra1 Rb1 Rc1 Rd1 Re1 Rf1 Rg1 Rh1 Ha2 Db2 Cc2 Md2 Ee2 Cf2 Dg2 Hh2
+1s ha7 db7 cc7 ed7 me7 cf7 dg7 hh7 ra8 rb8 rc8 rd8 re8 rf8 rg8 rh8
+2g Ee2n Ee3n Ee4n Ee5n
+2s hh7s hh6s hh5s hh4s
+3g Ee6e Ef6x
+`
+	if got := l.String(); got != want {
+		t.Errorf("ParseMoveList(): got\n%s\nwant\n%s", got, want)
+	}
+
+	if _, err := ParseMoveList(strings.Replace(list, "Ee4n Ee5n", "Ee4s Ee3s", 1)); !errors.Is(err, ErrNoChange) {
+		t.Errorf("ParseMoveList(): got error %v, want %v", err, ErrNoChange)
+	}
+}
@@ -17,7 +17,13 @@ type Options struct {
 
 func newOptions() *Options {
 	o := &Options{data: make(map[string]interface{})}
-	o.ExecuteSetOption("name playouts value 1")
+	o.ExecuteSetOption("name playouts value 0")
+	o.ExecuteSetOption(fmt.Sprintf("name cpuct value %v", defaultCPuct))
+	o.ExecuteSetOption(fmt.Sprintf("name fpu value %v", defaultFPU))
+	o.ExecuteSetOption("name dirichletalpha value 0.3")
+	o.ExecuteSetOption("name dirichletfraction value 0.25")
+	o.ExecuteSetOption("name temperature value 1")
+	o.ExecuteSetOption("name temperaturemoves value 10")
 	return o
 }
 
@@ -56,23 +62,29 @@ var setOptionPattern = regexp.MustCompile(`^name (\S+) value (\S+)$`)
 // Example input:
 //	"name foo_option value 10"
 func (o *Options) ExecuteSetOption(s string) error {
+	_, _, err := o.executeSetOption(s)
+	return err
+}
+
+// executeSetOption is like ExecuteSetOption but also returns the option name and parsed value.
+func (o *Options) executeSetOption(s string) (name string, value interface{}, err error) {
 	matches := setOptionPattern.FindStringSubmatch(strings.TrimSpace(s))
 	if len(matches) != 3 {
-		return fmt.Errorf("setoption does not match /%s/", setOptionPattern.String())
+		return "", nil, fmt.Errorf("setoption does not match /%s/", setOptionPattern.String())
 	}
 	name, strVal := matches[1], matches[2]
 	handler := globalSetOptions[name]
 	if handler == nil {
-		return fmt.Errorf("unrecognized option: %s", name)
+		return "", nil, fmt.Errorf("unrecognized option: %s", name)
 	}
-	value, err := handler(strVal)
+	value, err = handler(strVal)
 	if err != nil {
-		return err
+		return "", nil, err
 	}
 	o.m.Lock()
 	defer o.m.Unlock()
 	o.data[name] = value
-	return nil
+	return name, value, nil
 }
 
 var globalSetOptions = make(map[string]func(strVal string) (value interface{}, err error))
@@ -92,6 +104,26 @@ func setIntOptionFunc() func(s string) (value interface{}, err error) {
 	}
 }
 
+func setFloatOptionFunc() func(s string) (value interface{}, err error) {
+	return func(s string) (value interface{}, err error) {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+}
+
+func setTimeControlOptionFunc() func(s string) (value interface{}, err error) {
+	return func(s string) (value interface{}, err error) {
+		tc, err := ParseTimeControl(s)
+		if err != nil {
+			return nil, err
+		}
+		return tc, nil
+	}
+}
+
 func init() {
 	RegisterSetOption("tcmove", setIntOptionFunc())
 	RegisterSetOption("tcreserve", setIntOptionFunc())
@@ -104,4 +136,17 @@ func init() {
 	RegisterSetOption("sreserve", setIntOptionFunc())
 	RegisterSetOption("hash", setIntOptionFunc())
 	RegisterSetOption("goroutines", setIntOptionFunc())
+	RegisterSetOption("playouts", setIntOptionFunc())
+	RegisterSetOption("batchsize", setIntOptionFunc())
+	RegisterSetOption("batchtimeout", setIntOptionFunc())
+	RegisterSetOption("cpuct", setFloatOptionFunc())
+	RegisterSetOption("fpu", setFloatOptionFunc())
+	RegisterSetOption("dirichletalpha", setFloatOptionFunc())
+	RegisterSetOption("dirichletfraction", setFloatOptionFunc())
+	RegisterSetOption("temperature", setFloatOptionFunc())
+	RegisterSetOption("temperaturemoves", setIntOptionFunc())
+
+	// Extended options:
+
+	RegisterSetOption("tc", setTimeControlOptionFunc())
 }
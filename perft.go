@@ -0,0 +1,103 @@
+package zoo
+
+// PerftSteps returns the number of leaves of the step tree of p at the given depth.
+// Each legal step and each legal pass is a node as in the search tree.
+// Game end is not considered.
+func (p *Pos) PerftSteps(depth int) uint64 {
+	return p.Clone().perftSteps(depth)
+}
+
+func (p *Pos) perftSteps(depth int) uint64 {
+	if depth == 0 {
+		return 1
+	}
+	var n uint64
+	if p.CanPass() {
+		p.Pass()
+		n += p.perftSteps(depth - 1)
+		p.Unpass()
+	}
+	var steps []ExtStep
+	p.generateSteps(&steps)
+	for _, s := range steps {
+		if !p.Legal(s.Step) {
+			continue
+		}
+		p.Step(s.Step)
+		n += p.perftSteps(depth - 1)
+		p.Unstep()
+	}
+	return n
+}
+
+// PerftTurns returns the number of leaves of the tree of turns generated by
+// GenerateMoves from p at the given depth. Game end is not considered.
+func (p *Pos) PerftTurns(depth int) uint64 {
+	return p.Clone().perftTurns(depth)
+}
+
+func (p *Pos) perftTurns(depth int) uint64 {
+	if depth == 0 {
+		return 1
+	}
+	var n uint64
+	for _, m := range p.GenerateMoves() {
+		p.Move(m)
+		n += p.perftTurns(depth - 1)
+		p.undoMove(m)
+	}
+	return n
+}
+
+// undoMove undoes the rest of the turn m played with Move.
+// Unlike Unmove, steps taken before m in the same turn are kept.
+func (p *Pos) undoMove(m Move) {
+	p.Unpass()
+	for n := m.Len(); n > 0; n-- {
+		p.Unstep()
+	}
+}
+
+// PerftDivision is the number of leaves under a first step or turn.
+// The Move is nil for a pass.
+type PerftDivision struct {
+	Move   Move
+	Leaves uint64
+}
+
+// PerftDivide returns the number of leaves under each first step or turn of p at
+// the given depth. Turns are used if turns is true and steps are used otherwise.
+func (p *Pos) PerftDivide(depth int, turns bool) []PerftDivision {
+	if depth <= 0 {
+		return nil
+	}
+	p = p.Clone()
+	var res []PerftDivision
+	if turns {
+		for _, m := range p.GenerateMoves() {
+			p.Move(m)
+			res = append(res, PerftDivision{m, p.perftTurns(depth - 1)})
+			p.undoMove(m)
+		}
+		return res
+	}
+	if p.CanPass() {
+		p.Pass()
+		res = append(res, PerftDivision{nil, p.perftSteps(depth - 1)})
+		p.Unpass()
+	}
+	var steps []ExtStep
+	p.generateSteps(&steps)
+	for _, s := range steps {
+		if !p.Legal(s.Step) {
+			continue
+		}
+		m := Move{s.Step}
+		if cap := p.Step(s.Step); cap.Capture() {
+			m = append(m, cap)
+		}
+		res = append(res, PerftDivision{m, p.perftSteps(depth - 1)})
+		p.Unstep()
+	}
+	return res
+}
@@ -0,0 +1,364 @@
+package zoo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// readTestPosition reads the position of the first setposition command in the testdata file name.
+func readTestPosition(t testing.TB, name string) *Pos {
+	t.Helper()
+	p, err := ParseShortPosition(readTestShortPosition(t, name))
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return p
+}
+
+// perftTestCases are leaf counts of the testdata positions. They are not taken from a
+// published table of Arimaa perft counts. TestPerftReference checks them against refPos,
+// an implementation of the step rules which shares no code with Pos, so a change to the
+// move generator must agree with both to pass.
+var perftTestCases = []struct {
+	name  string
+	steps []uint64 // leaves at step depths 1, 2, ...
+	turns uint64   // leaves at turn depth 1.
+}{
+	{"endgame_1.txt", []uint64{17, 310, 5620, 101826}, 5337},
+	{"game_489557.txt", []uint64{20, 412, 8562, 170971}, 12476},
+	{"juhnke_11.txt", []uint64{23, 435, 8433, 135956}, 10197},
+	{"mate_threat.txt", []uint64{16, 245, 3994, 64463}, 4622},
+	{"test_position_1.txt", []uint64{25, 571, 13065, 262616}, 15574},
+}
+
+func TestPerft(t *testing.T) {
+	for _, tc := range perftTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := readTestPosition(t, tc.name)
+			hash := p.Hash()
+			for i, want := range tc.steps {
+				if got := p.PerftSteps(i + 1); got != want {
+					t.Errorf("PerftSteps(%d): got %d, want %d", i+1, got, want)
+				}
+			}
+			if got := p.PerftTurns(1); got != tc.turns {
+				t.Errorf("PerftTurns(1): got %d, want %d", got, tc.turns)
+			}
+			if p.Hash() != hash {
+				t.Errorf("Perft(): position changed")
+			}
+		})
+	}
+}
+
+// refPos is a reference implementation of the Arimaa step rules for checking perft counts.
+// It shares no code with Pos: the board holds piece strengths from 1 (rabbit) to 6
+// (elephant), positive for gold and negative for silver, indexed by rank*8+file.
+type refPos struct {
+	board     [64]int8
+	start     [64]int8 // board at the start of the turn.
+	side      int8     // 1 for gold and -1 for silver.
+	stepsLeft int
+	pushSq    int  // square left by the piece being pushed or -1.
+	pushStr   int8 // strength of the piece being pushed.
+	pullSq    int  // square left by the last friendly step if it can pull or -1.
+	pullStr   int8 // strength of the piece of the last friendly step.
+}
+
+// newRefPos parses the position in short notation.
+func newRefPos(s string) (refPos, error) {
+	if len(s) != 68 || s[2] != '[' || s[67] != ']' {
+		return refPos{}, fmt.Errorf("bad position: %q", s)
+	}
+	r := refPos{side: 1, stepsLeft: 4, pushSq: -1, pullSq: -1}
+	if s[0] == 's' || s[0] == 'b' {
+		r.side = -1
+	}
+	for i, c := range s[3:67] {
+		sq := 8*(7-i/8) + i%8
+		if c == ' ' {
+			continue
+		}
+		str := int8(strings.IndexRune("RCDHME", unicode.ToUpper(c)) + 1)
+		if str == 0 {
+			return refPos{}, fmt.Errorf("bad piece: %q", c)
+		}
+		if unicode.IsLower(c) {
+			str = -str
+		}
+		r.board[sq] = str
+	}
+	r.start = r.board
+	return r, nil
+}
+
+func refNeighbors(sq int) []int {
+	var ns []int
+	if sq >= 8 {
+		ns = append(ns, sq-8)
+	}
+	if sq < 56 {
+		ns = append(ns, sq+8)
+	}
+	if sq%8 > 0 {
+		ns = append(ns, sq-1)
+	}
+	if sq%8 < 7 {
+		ns = append(ns, sq+1)
+	}
+	return ns
+}
+
+func refStrength(v int8) int8 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// frozen returns true if the piece on sq has no friendly neighbor and a stronger enemy neighbor.
+func (r *refPos) frozen(sq int) bool {
+	v := r.board[sq]
+	var stronger bool
+	for _, n := range refNeighbors(sq) {
+		switch w := r.board[n]; {
+		case w*v > 0:
+			return false
+		case w*v < 0 && refStrength(w) > refStrength(v):
+			stronger = true
+		}
+	}
+	return stronger
+}
+
+// capture removes pieces on traps without friendly neighbors.
+func (r *refPos) capture() {
+	for _, t := range []int{18, 21, 42, 45} {
+		v := r.board[t]
+		if v == 0 {
+			continue
+		}
+		supported := false
+		for _, n := range refNeighbors(t) {
+			if r.board[n]*v > 0 {
+				supported = true
+			}
+		}
+		if !supported {
+			r.board[t] = 0
+		}
+	}
+}
+
+func (r *refPos) endTurn() {
+	r.side = -r.side
+	r.stepsLeft = 4
+	r.start = r.board
+	r.pushSq, r.pullSq = -1, -1
+}
+
+// canPass returns true if the turn can end now.
+func (r *refPos) canPass() bool {
+	return r.stepsLeft < 4 && r.pushSq < 0 && r.board != r.start
+}
+
+// step returns the position after the piece on src steps to the empty square dest.
+// The turn ends after the fourth step.
+func (r refPos) step(src, dest int) (refPos, bool) {
+	v := r.board[src]
+	str := refStrength(v)
+	friendly := v*r.side > 0
+	var push bool
+	if friendly {
+		if r.frozen(src) {
+			return r, false
+		}
+		if str == 1 && dest-src == -8*int(r.side) {
+			// Rabbits do not step backward.
+			return r, false
+		}
+		if r.pushSq >= 0 && (dest != r.pushSq || str <= r.pushStr) {
+			// A push is completed by a stronger piece.
+			return r, false
+		}
+	} else {
+		if r.pushSq >= 0 {
+			return r, false
+		}
+		if dest != r.pullSq || str >= r.pullStr {
+			// Not a pull so this starts a push by a stronger unfrozen friendly piece.
+			if r.stepsLeft < 2 {
+				return r, false
+			}
+			for _, n := range refNeighbors(src) {
+				if w := r.board[n]; w*r.side > 0 && refStrength(w) > str && !r.frozen(n) {
+					push = true
+				}
+			}
+			if !push {
+				return r, false
+			}
+		}
+	}
+	q := r
+	q.board[src], q.board[dest] = 0, v
+	q.capture()
+	q.stepsLeft--
+	q.pushSq, q.pullSq = -1, -1
+	switch {
+	case push:
+		q.pushSq, q.pushStr = src, str
+	case friendly && r.pushSq < 0:
+		q.pullSq, q.pullStr = src, str
+	}
+	if q.stepsLeft == 0 {
+		if q.board == q.start {
+			return r, false
+		}
+		q.endTurn()
+	}
+	return q, true
+}
+
+// children returns the positions after each legal step.
+func (r *refPos) children() []refPos {
+	var res []refPos
+	for src, v := range r.board {
+		if v == 0 {
+			continue
+		}
+		for _, dest := range refNeighbors(src) {
+			if r.board[dest] != 0 {
+				continue
+			}
+			if q, ok := r.step(src, dest); ok {
+				res = append(res, q)
+			}
+		}
+	}
+	return res
+}
+
+// perftSteps counts the leaves of the step tree including passes as PerftSteps.
+func (r refPos) perftSteps(depth int) uint64 {
+	if depth == 0 {
+		return 1
+	}
+	var n uint64
+	if r.canPass() {
+		q := r
+		q.endTurn()
+		n += q.perftSteps(depth - 1)
+	}
+	for _, q := range r.children() {
+		n += q.perftSteps(depth - 1)
+	}
+	return n
+}
+
+// turns adds the distinct boards at the end of the turn to ends.
+func (r refPos) turns(ends map[[64]int8]bool) {
+	if r.canPass() {
+		ends[r.board] = true
+	}
+	for _, q := range r.children() {
+		if q.side != r.side {
+			ends[q.board] = true
+			continue
+		}
+		q.turns(ends)
+	}
+}
+
+// readTestShortPosition returns the position of the first setposition command in the testdata file name.
+func readTestShortPosition(t testing.TB, name string) string {
+	t.Helper()
+	bs, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(string(bs), "\n") {
+		if s := strings.TrimSpace(line); strings.HasPrefix(s, "setposition ") {
+			return strings.TrimPrefix(s, "setposition ")
+		}
+	}
+	t.Fatalf("%s: no setposition command", name)
+	return ""
+}
+
+func TestPerftReference(t *testing.T) {
+	for _, tc := range perftTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := newRefPos(readTestShortPosition(t, tc.name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, want := range tc.steps {
+				if got := r.perftSteps(i + 1); got != want {
+					t.Errorf("reference steps(%d): got %d, want %d", i+1, got, want)
+				}
+			}
+			ends := make(map[[64]int8]bool)
+			r.turns(ends)
+			if got := uint64(len(ends)); got != tc.turns {
+				t.Errorf("reference turns(1): got %d, want %d", got, tc.turns)
+			}
+		})
+	}
+}
+
+func TestPerftDivide(t *testing.T) {
+	p := readTestPosition(t, "endgame_1.txt")
+	for _, tc := range []struct {
+		name  string
+		depth int
+		turns bool
+		want  uint64
+	}{
+		{"steps", 3, false, p.PerftSteps(3)},
+		{"turns", 1, true, p.PerftTurns(1)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got uint64
+			for _, d := range p.PerftDivide(tc.depth, tc.turns) {
+				got += d.Leaves
+			}
+			if got != tc.want {
+				t.Errorf("PerftDivide(%d, %v): got %d leaves, want %d", tc.depth, tc.turns, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPerftCommand(t *testing.T) {
+	engine := newTestEngine(t, 0)
+	var out strings.Builder
+	engine.log = log.New(&out, "", 0)
+	for _, tc := range []struct {
+		command string
+		want    string // end of the output.
+	}{
+		{"perft 1", "steps 2\nturns 14"},
+		{"perft divide 1", "Ra1n Ra2n Ra3n Ra4n 1\nturns 14"},
+		{"perft divide steps 2", "Ra1e 4\nRa1n 3\nsteps 7"},
+	} {
+		out.Reset()
+		if err := engine.ExecuteCommand("setposition g [                                                        R       ]"); err != nil {
+			t.Fatal(err)
+		}
+		if err := engine.ExecuteCommand(tc.command); err != nil {
+			t.Fatalf("%s: %v", tc.command, err)
+		}
+		if got := strings.TrimSpace(out.String()); !strings.HasSuffix(got, tc.want) {
+			t.Errorf("%s: got output %q, want suffix %q", tc.command, got, tc.want)
+		}
+	}
+	if err := engine.ExecuteCommand("perft divide x"); err == nil {
+		t.Errorf("perft divide x: got nil error, want error")
+	}
+}
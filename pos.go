@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -14,22 +15,23 @@ const maxPly = 1024
 
 // Pos represents an Arimaa position.
 type Pos struct {
-	board      []Piece    // board information; captures are negated such that they can be undone later
-	bitboards  []Bitboard // bitboard data
-	presence   []Bitboard // board presence for each side
-	stronger   []Bitboard // stronger pieces by piece&decolorMask
-	weaker     []Bitboard // weaker pieces by piece&decolorMask
-	touching   []Bitboard // squares touched for each side
-	dominating []Bitboard // squares dominated by each side (touched by a nonrabbit)
-	frozen     []Bitboard // frozen squares for each (dominating) side
-	threefold  *Threefold // threefold repetition store
-	side       Color      // side to play
-	moveNum    int        // number of moves left for this turn
-	moves      MoveList   // moves to arrive at this position including the current in progress move
-	stepsLeft  int        // steps remaining in the current move
-	stack      []pushInfo // information allocated per step thats needs to be restored on unstep.
-	hash       Hash       // hash of the current position
-	turnHash   []Hash     // hash at the beginning of the turn used to detect repetition
+	board      []Piece     // board information; captures are negated such that they can be undone later
+	bitboards  []Bitboard  // bitboard data
+	presence   []Bitboard  // board presence for each side
+	stronger   []Bitboard  // stronger pieces by piece&decolorMask
+	weaker     []Bitboard  // weaker pieces by piece&decolorMask
+	touching   []Bitboard  // squares touched for each side
+	dominating []Bitboard  // squares dominated by each side (touched by a nonrabbit)
+	frozen     []Bitboard  // frozen squares for each (dominating) side
+	threefold  *Threefold  // threefold repetition store
+	side       Color       // side to play
+	moveNum    int         // number of moves left for this turn
+	moves      MoveList    // moves to arrive at this position including the current in progress move
+	stepsLeft  int         // steps remaining in the current move
+	stack      []pushInfo  // information allocated per step thats needs to be restored on unstep.
+	hash       Hash        // hash of the current position
+	turnHash   []Hash      // hash at the beginning of the turn used to detect repetition
+	goal       *goalResult // result of the last goal search (see Goal)
 }
 
 type pushInfo struct {
@@ -109,13 +111,110 @@ func ParseShortPosition(s string) (*Pos, error) {
 	if err != nil {
 		return nil, err
 	}
+	p, err := newBoardPosition(side, 2, matches[2])
+	if err != nil {
+		return nil, err
+	}
+	p.resetHistory(nil)
+	return p, nil
+}
+
+var fullPosPattern = regexp.MustCompile(`^([0-9]+)([wbgs]) \[([ RCDHMErcdhme]{64})\] (\S+) (\S+)$`)
+
+// ParseFullPosition parses the position in full notation:
+//
+//	<move number><color> [<board>] <steps> <history>
+//
+// The board is the current board as in short notation. Steps are the steps
+// taken so far in the turn separated by commas, including captures. History
+// are the hexadecimal hashes of the positions at the start of previous turns
+// separated by commas. Either is "-" if empty. Steps left in the turn and an
+// ongoing push are restored by replaying the steps.
+func ParseFullPosition(s string) (*Pos, error) {
+	matches := fullPosPattern.FindStringSubmatch(s)
+	if matches == nil {
+		return nil, fmt.Errorf("input does not match /%s/", fullPosPattern)
+	}
+	moveNum, err := strconv.Atoi(matches[1])
+	if err != nil || moveNum < 1 {
+		return nil, fmt.Errorf("bad move number: %s", matches[1])
+	}
+	side, err := ParseColor(matches[2][0])
+	if err != nil {
+		return nil, err
+	}
+	var steps Move
+	if matches[4] != "-" {
+		for _, f := range strings.Split(matches[4], ",") {
+			step, err := ParseStep(f)
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, step)
+		}
+	}
+	var history []Hash
+	if matches[5] != "-" {
+		for _, f := range strings.Split(matches[5], ",") {
+			h, err := strconv.ParseUint(f, 16, 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad hash: %s", f)
+			}
+			history = append(history, Hash(h))
+		}
+	}
+	p, err := newBoardPosition(side, moveNum, matches[3])
+	if err != nil {
+		return nil, err
+	}
+
+	// Undo the steps to find the board at the start of the turn.
+	for i := len(steps) - 1; i >= 0; i-- {
+		step := steps[i]
+		switch {
+		case step.Capture():
+			p.Place(step.Piece(), step.Src())
+		case step.Setup():
+			p.Remove(step.Piece(), step.Dest())
+		default:
+			p.Remove(step.Piece(), step.Dest())
+			p.Place(step.Piece(), step.Src())
+		}
+	}
+	p.resetHistory(history)
+	for _, step := range steps {
+		if step.Capture() {
+			continue
+		}
+		if !p.Legal(step) {
+			return nil, fmt.Errorf("illegal step: %s", step)
+		}
+		p.Step(step)
+	}
+	if !p.currentMove().Equals(steps) || p.boardString() != matches[3] {
+		return nil, fmt.Errorf("steps do not lead to the board")
+	}
+	return p, nil
+}
+
+// ParsePosition parses the position in full or short notation.
+func ParsePosition(s string) (*Pos, error) {
+	if s != "" && s[0] >= '0' && s[0] <= '9' {
+		return ParseFullPosition(s)
+	}
+	return ParseShortPosition(s)
+}
+
+// newBoardPosition creates the position with the pieces of the board in short
+// notation at the start of the turn of side.
+func newBoardPosition(side Color, moveNum int, board string) (*Pos, error) {
 	p := NewEmptyPosition()
-	p.Pass()
-	p.Pass()
-	if p.side != side {
-		p.Pass()
+	p.side = side
+	p.moveNum = moveNum
+	if moveNum > 1 {
+		p.stepsLeft = 4
 	}
-	for i, b := range []byte(matches[2]) {
+	for i, b := range []byte(board) {
 		square := Square(8*(7-i/8) + i%8)
 		piece, err := ParsePiece(b)
 		if err != nil {
@@ -129,6 +228,18 @@ func ParseShortPosition(s string) (*Pos, error) {
 	return p, nil
 }
 
+// resetHistory sets the hashes of the positions at the start of previous turns
+// to history. The current position is the start of the turn.
+func (p *Pos) resetHistory(history []Hash) {
+	p.hash = computeHash(p.bitboards, p.side, p.stepsLeft)
+	p.threefold.Clear()
+	p.turnHash = p.turnHash[:0]
+	for _, h := range append(history, p.hash) {
+		p.threefold.Increment(h)
+		p.turnHash = append(p.turnHash, h)
+	}
+}
+
 // Clone returns a deep copy of the position p.
 func (p *Pos) Clone() *Pos {
 	board := make([]Piece, 64)
@@ -253,65 +364,89 @@ func (p *Pos) LastStep() bool {
 	return p.stepsLeft == 1
 }
 
-// Terminal tests whether this position is a terminal node from the perspecitve of player B to move.
-// Only checks for goal, elimination, and immobilization.
-func (p *Pos) Terminal() Value {
+// Terminal returns the result of the game from the perspective of player B to move.
+// The game can only end at the start of a turn. The rules are applied in order:
+//
+//	player A's move left the position unchanged: player B wins,
+//	player A's move repeated the position for the third time: player B wins,
+//	player A's rabbit reached goal: player A wins,
+//	player B's rabbit reached goal: player B wins,
+//	player B lost all rabbits: player A wins,
+//	player A lost all rabbits: player B wins,
+//	player B has no legal move: player A wins.
+func (p *Pos) Terminal() Result {
+	// Still setting up or in the middle of a turn?
+	if p.moveNum == 1 || len(*p.currentMove()) > 0 {
+		return Result{}
+	}
 
-	// Still setting up?
-	if p.moveNum == 1 {
-		return 0
+	// Did player A make an illegal move?
+	if n := len(p.turnHash); n >= 2 && p.turnHash[n-1] == p.turnHash[n-2]^silverHashKey() {
+		return Result{Win, ReasonNoChange}
+	}
+	if p.threefold.Lookup(p.Hash()) >= 3 {
+		return Result{Win, ReasonRepetition}
 	}
 
 	c := p.Side()
 
 	// Goal:
-	goalA := p.bitboards[GRabbit] & ^NotRank8 != 0
-	goalB := p.bitboards[SRabbit] & ^NotRank1 != 0
-	if c == Gold {
-		goalA, goalB = goalB, goalA
-	}
+	goalA := p.bitboards[GRabbit.WithColor(c.Opposite())]&goalRank(c.Opposite()) != 0
+	goalB := p.bitboards[GRabbit.WithColor(c)]&goalRank(c) != 0
 
 	// Has a rabbit of player A reached goal? If so player A wins.
 	if goalA {
-		return Loss
+		return Result{Loss, ReasonGoal}
 	}
 
 	// Has a rabbit of player B reached goal? If so player B wins.
 	if goalB {
-		return Win
+		return Result{Win, ReasonGoal}
 	}
 
 	// Elimination:
-	elimA := p.bitboards[GRabbit] == 0
-	elimB := p.bitboards[SRabbit] == 0
-	if c == Gold {
-		elimA, elimB = elimB, elimA
-	}
+	elimA := p.bitboards[GRabbit.WithColor(c.Opposite())] == 0
+	elimB := p.bitboards[GRabbit.WithColor(c)] == 0
 
 	// Has player B lost all rabbits? If so player A wins.
 	if elimB {
-		return Loss
+		return Result{Loss, ReasonElimination}
 	}
 
 	// Has player A lost all rabbits? If so player B wins.
 	if elimA {
-		return Win
+		return Result{Win, ReasonElimination}
 	}
 
 	// Has player B become immobilized? If so player A wins.
-	var mobile bool
-	for b := p.Presence(p.Side()); b > 0; b &= b - 1 {
-		src := b.Square()
-		if !p.Frozen(src) {
-			mobile = true
-			break
-		}
-	}
-	if !mobile {
-		return Loss
+	if !p.hasLegalMove() {
+		return Result{Loss, ReasonImmobilization}
 	}
 
-	return 0
+	return Result{}
+}
+
+// hasLegalMove returns true if the side to move can complete a legal turn.
+// The steps tried are undone before returning.
+func (p *Pos) hasLegalMove() bool {
+	c := p.Side()
+	if p.CanPass() {
+		return true
+	}
+	var steps []ExtStep
+	p.generateSteps(&steps)
+	for _, s := range steps {
+		if !p.Legal(s.Step) {
+			continue
+		}
+		p.Step(s.Step)
+		ok := p.Side() != c || p.hasLegalMove()
+		p.Unstep()
+		if ok {
+			return true
+		}
+	}
+	return false
 }
 
 // Place places piece on i. If piece is Empty it instead removes the piece.
@@ -410,25 +545,30 @@ func (p *Pos) Push() (src Square, piece Piece, ok bool) {
 
 var setupCounts = []uint8{0, 8, 2, 2, 2, 1, 1}
 
-// Legal checks the legality of a step in the context of an ongoing move
-// and returns ok and an error if any.
+// Legal checks the legality of a step in the context of an ongoing move.
 // Legal is meant to be called before playing s.
 func (p *Pos) Legal(s Step) bool {
+	return p.checkStep(s) == nil
+}
+
+// checkStep returns the reason why s is not legal in the context of the
+// ongoing move or nil if it is legal.
+func (p *Pos) checkStep(s Step) error {
 
 	// We don't try to validate captures.
 	if s.Capture() {
-		return false
+		return ErrCapture
 	}
 
 	// Steps left?
 	if p.stepsLeft == 0 {
-		return false
+		return ErrTooManySteps
 	}
 
 	// Is piece valid?
 	piece := s.Piece()
 	if !piece.Valid() {
-		return false
+		return ErrNoPiece
 	}
 
 	dest := s.Dest()
@@ -436,46 +576,51 @@ func (p *Pos) Legal(s Step) bool {
 	if s.Setup() {
 		// Setup move after move 1?
 		if p.moveNum != 1 {
-			return false
+			return ErrSetup
 		}
 
 		// Check setup piece:
 		if piece.Color() != p.Side() {
-			return false
+			return ErrSetup
 		}
 		if t := piece.RemoveColor(); p.Bitboard(piece).Count() >= setupCounts[t] {
-			return false
+			return ErrSetup
 		}
 
 		// Check setup square:
 		if c := p.Side(); c == Gold && dest > H2 || c == Silver && dest < A6 {
-			return false
+			return ErrSetup
 		}
 
-		return true
+		return nil
+	}
+
+	// Setup steps are required on move 1.
+	if p.moveNum == 1 {
+		return ErrSetup
 	}
 
 	// Is src valid?
 	src := s.Src()
-	if t := p.At(src); !t.Valid() {
-		return false
+	if t := p.At(src); t != piece {
+		return ErrNoPiece
 	}
 
 	// Is dest empty?
 	if t := p.At(dest); t != Empty {
-		return false
+		return ErrOccupied
 	}
 
 	// Move to non-adjacent square?
 	if src.Neighbors()&dest.Bitboard() == 0 {
-		return false
+		return ErrNotAdjacent
 	}
 
 	if piece.Color() == p.Side() {
 
 		// Is src frozen?
 		if piece.Color() == p.Side() && p.Frozen(src) {
-			return false
+			return ErrFrozen
 		}
 
 		// Backwards rabbit move?
@@ -483,24 +628,24 @@ func (p *Pos) Legal(s Step) bool {
 		if piece.SameType(GRabbit) &&
 			(piece.Color() == Gold && direction == South ||
 				piece.Color() == Silver && direction == North) {
-			return false
+			return ErrBackwardRabbit
 		}
 
 		// Check that this step completes the last push if any.
 		if src, t, ok := p.Push(); ok && (dest != src || !t.WeakerThan(piece)) {
-			return false
+			return ErrBadPush
 		}
 	} else {
 		// Step abandons ongoing push.
 		lastSrc, lastPiece, ok := p.Push()
 		if ok {
-			return false
+			return ErrBadPush
 		}
 
 		if lastPiece == Empty || lastSrc != dest || !piece.WeakerThan(lastPiece) {
 			// Push on last step.
 			if p.stepsLeft == 1 {
-				return false
+				return ErrBadPush
 			}
 
 			// Find a valid pusher.
@@ -512,7 +657,7 @@ func (p *Pos) Legal(s Step) bool {
 				}
 			}
 			if !strongerUnfrozen {
-				return false
+				return ErrBadPush
 			}
 		}
 	}
@@ -522,48 +667,60 @@ func (p *Pos) Legal(s Step) bool {
 
 		// Does this step end the turn and repeat a position for the third time?
 		if p.threefold.Lookup(hashAfter) >= 2 {
-			return false
+			return ErrRepetition
 		}
 
 		// Does this step repeat the position?
 		if hashAfter == p.turnHash[len(p.turnHash)-1]^silverHashKey() {
-			return false
+			return ErrNoChange
 		}
 	}
 
-	return true
+	return nil
 }
 
 // CanPass returns true when passing the turn would be a legal move.
 // It considers the number of steps taken so far, push progress,
 // and threefold repetitions.
 func (p *Pos) CanPass() bool {
+	return p.checkPass() == nil
+}
+
+// checkPass returns the reason why passing the turn is not legal or nil if it is legal.
+func (p *Pos) checkPass() error {
 	// Never pass during setup.
 	if p.moveNum == 1 {
-		return false
+		return ErrSetup
 	}
 
 	// We need to make at least one step.
 	if p.stepsLeft == 4 {
-		return false
+		return ErrEmptyMove
 	}
 
 	// Are we in the middle of a push?
 	if _, _, ok := p.Push(); ok {
-		return false
+		return ErrBadPush
 	}
 
+	hashAfter := p.hashAfterPass()
+
 	// Would the position would repeat for a third time if we passed?
-	if p.threefold.Lookup(p.Hash()^silverHashKey()) >= 2 {
-		return false
+	if p.threefold.Lookup(hashAfter) >= 2 {
+		return ErrRepetition
 	}
 
 	// Would the move repeat if we passed now?
-	if p.Hash() == p.turnHash[len(p.turnHash)-1]^silverHashKey() {
-		return false
+	if hashAfter == p.turnHash[len(p.turnHash)-1]^silverHashKey() {
+		return ErrNoChange
 	}
 
-	return true
+	return nil
+}
+
+// hashAfterPass returns the hash that would result after passing the turn.
+func (p *Pos) hashAfterPass() Hash {
+	return p.Hash() ^ silverHashKey() ^ stepsHashKey(p.stepsLeft) ^ stepsHashKey(4)
 }
 
 // Pass the turn and reset step variables.
@@ -707,8 +864,8 @@ func (p *Pos) Move(m Move) {
 
 func (p *Pos) Unmove() {
 	p.Unpass()
-	move := p.currentMove()
-	for i := len(*move) - 1; i >= 0; i-- {
+	// Unstep also restores captures.
+	for n := p.currentMove().Len(); n > 0; n-- {
 		p.Unstep()
 	}
 }
@@ -730,6 +887,42 @@ func (p *Pos) ShortString() string {
 	return sb.String()
 }
 
+// boardString returns the board in short notation.
+func (p *Pos) boardString() string {
+	s := p.ShortString()
+	return s[3 : len(s)-1]
+}
+
+// FullString returns the position in full notation (see ParseFullPosition).
+func (p *Pos) FullString() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d", p.moveNum)
+	p.appendShortString(&sb)
+	sb.WriteByte(' ')
+	if move := *p.currentMove(); len(move) > 0 {
+		for i, step := range move {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			sb.WriteString(step.String())
+		}
+	} else {
+		sb.WriteByte('-')
+	}
+	sb.WriteByte(' ')
+	if history := p.turnHash[:len(p.turnHash)-1]; len(history) > 0 {
+		for i, h := range history {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			fmt.Fprintf(&sb, "%x", uint64(h))
+		}
+	} else {
+		sb.WriteByte('-')
+	}
+	return sb.String()
+}
+
 func (p *Pos) appendString(sb *strings.Builder) {
 	fmt.Fprintf(sb, "%d%c", p.moveNum, p.side.Byte())
 	if move := p.currentMove(); move != nil {
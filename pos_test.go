@@ -315,9 +315,263 @@ func TestCanPass(t *testing.T) {
 		steps: []Step{
 			MakeStep(GDog, D7, D6),
 		},
+	}, {
+		name:          "position unchanged",
+		shortPosition: "g [rrrrrrrrhdcemcdh                                HDCMECDHRRRRRRRR]",
+		steps: []Step{
+			MakeStep(GElephant, E2, E3),
+			MakeStep(GElephant, E3, E2),
+		},
+	}, {
+		name:          "position changed",
+		shortPosition: "g [rrrrrrrrhdcemcdh                                HDCMECDHRRRRRRRR]",
+		steps: []Step{
+			MakeStep(GElephant, E2, E3),
+		},
+		want: true,
 	}} {
 		t.Run(tc.name, func(t *testing.T) {
 			runCanPassTestCase(t, tc)
 		})
 	}
 }
+
+func TestTerminal(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		shortPosition string
+		moveNum       int
+		steps         []Step
+		repetitions   int  // additional occurrences of the position.
+		pass          bool // pass after steps even if illegal.
+		want          Result
+	}{{
+		name:          "not over",
+		shortPosition: "s [rrrrrrrrhdcemcdh                                HDCMECDHRRRRRRRR]",
+	}, {
+		name:          "setup",
+		shortPosition: "g [                                                                ]",
+		moveNum:       1,
+	}, {
+		name:          "goal during the turn",
+		shortPosition: "g [        R      r            e                               E   ]",
+		steps:         []Step{MakeStep(GRabbit, A7, A8)},
+	}, {
+		name:          "goal by player A",
+		shortPosition: "s [R      r                           Ee                           ]",
+		want:          Result{Loss, ReasonGoal},
+	}, {
+		name:          "goal by both players",
+		shortPosition: "s [R                                  Ee                   r       ]",
+		want:          Result{Loss, ReasonGoal},
+	}, {
+		name:          "goal by player B",
+		shortPosition: "s [                                   Ee                  Rr       ]",
+		want:          Result{Win, ReasonGoal},
+	}, {
+		name:          "elimination of player B",
+		shortPosition: "s [                                   Ee           R               ]",
+		want:          Result{Loss, ReasonElimination},
+	}, {
+		name:          "elimination of both players",
+		shortPosition: "s [                                   Ee                           ]",
+		want:          Result{Loss, ReasonElimination},
+	}, {
+		name:          "elimination of player A",
+		shortPosition: "s [               r                   Ee                           ]",
+		want:          Result{Win, ReasonElimination},
+	}, {
+		name:          "immobilization",
+		shortPosition: "s [rE                                                          R   ]",
+		want:          Result{Loss, ReasonImmobilization},
+	}, {
+		name:          "third repetition",
+		shortPosition: "s [rrrrrrrrhdcemcdh                                HDCMECDHRRRRRRRR]",
+		repetitions:   2,
+		want:          Result{Win, ReasonRepetition},
+	}, {
+		name:          "second repetition",
+		shortPosition: "s [rrrrrrrrhdcemcdh                                HDCMECDHRRRRRRRR]",
+		repetitions:   1,
+	}, {
+		name:          "no change",
+		shortPosition: "g [rrrrrrrrhdcemcdh                                HDCMECDHRRRRRRRR]",
+		steps: []Step{
+			MakeStep(GElephant, E2, E3),
+			MakeStep(GElephant, E3, E2),
+		},
+		pass: true,
+		want: Result{Win, ReasonNoChange},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := ParseShortPosition(tc.shortPosition)
+			if err != nil {
+				t.Fatalf("ParseShortPosition(%q): %v", tc.shortPosition, err)
+			}
+			if tc.moveNum != 0 {
+				p.moveNum = tc.moveNum
+				if p.moveNum == 1 {
+					p.stepsLeft = 16
+				}
+			}
+			for _, step := range tc.steps {
+				if !p.Legal(step) {
+					t.Fatalf("Intermediate step is not legal: %s", step)
+				}
+				p.Step(step)
+			}
+			if tc.pass {
+				p.Pass()
+			}
+			for i := 0; i < tc.repetitions; i++ {
+				p.threefold.Increment(p.Hash())
+			}
+			hash := p.Hash()
+			if got := p.Terminal(); got != tc.want {
+				t.Errorf("Terminal(): got %v %s, want %v %s", got.Value, got.Reason, tc.want.Value, tc.want.Reason)
+			}
+			if p.Hash() != hash {
+				t.Errorf("Terminal(): position changed")
+			}
+		})
+	}
+}
+
+func TestFullString(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		shortPosition string
+		moveNum       int
+		moves         []string // complete turns.
+		steps         []Step   // steps of the current turn.
+		want          string
+	}{{
+		name:          "opening",
+		shortPosition: "g [rrrrrrrrhdcemcdh                                HDCMECDHRRRRRRRR]",
+		want:          "2g [rrrrrrrrhdcemcdh                                HDCMECDHRRRRRRRR] - -",
+	}, {
+		name:          "setup",
+		shortPosition: "g [                                                                ]",
+		moveNum:       1,
+		steps: []Step{
+			MakeSetup(GElephant, E2),
+			MakeSetup(GRabbit, A1),
+		},
+	}, {
+		name:          "in push",
+		shortPosition: "g [                                  r       Cr      D             ]",
+		steps: []Step{
+			MakeStep(SRabbit, D3, D4),
+		},
+	}, {
+		name:          "capture",
+		shortPosition: "g [       r                   r       E                            ]",
+		steps: []Step{
+			MakeStep(GElephant, D4, D3),
+			MakeStep(SRabbit, D5, D4),
+			MakeStep(GElephant, D3, C3),
+		},
+	}, {
+		name:          "history",
+		shortPosition: "g [rrrrrrrrhdcemcdh                                HDCMECDHRRRRRRRR]",
+		moves:         []string{"Ee2n", "ed7s", "Ee3s", "ed6n"},
+		steps: []Step{
+			MakeStep(GElephant, E2, E3),
+		},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := ParseShortPosition(tc.shortPosition)
+			if err != nil {
+				t.Fatalf("ParseShortPosition(%q): %v", tc.shortPosition, err)
+			}
+			if tc.moveNum != 0 {
+				p.moveNum = tc.moveNum
+				if p.moveNum == 1 {
+					p.stepsLeft = 16
+				}
+				p.resetHistory(nil)
+			}
+			for _, s := range tc.moves {
+				m, err := ParseMove(s)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, step := range m {
+					if !p.Legal(step) {
+						t.Fatalf("Intermediate step is not legal: %s", step)
+					}
+					p.Step(step)
+				}
+				p.Pass()
+			}
+			for _, step := range tc.steps {
+				if !p.Legal(step) {
+					t.Fatalf("Intermediate step is not legal: %s", step)
+				}
+				p.Step(step)
+			}
+
+			s := p.FullString()
+			if tc.want != "" && s != tc.want {
+				t.Errorf("FullString(): got %q, want %q", s, tc.want)
+			}
+			got, err := ParsePosition(s)
+			if err != nil {
+				t.Fatalf("ParsePosition(%q): %v", s, err)
+			}
+			if gotS := got.FullString(); gotS != s {
+				t.Errorf("ParsePosition(%q): got %q", s, gotS)
+			}
+			if got.Hash() != p.Hash() || got.moveNum != p.moveNum || got.stepsLeft != p.stepsLeft {
+				t.Errorf("ParsePosition(%q): got hash=%d move=%d steps=%d, want hash=%d move=%d steps=%d",
+					s, got.Hash(), got.moveNum, got.stepsLeft, p.Hash(), p.moveNum, p.stepsLeft)
+			}
+			gotSrc, gotPiece, gotPush := got.Push()
+			src, piece, push := p.Push()
+			if gotSrc != src || gotPiece != piece || gotPush != push {
+				t.Errorf("ParsePosition(%q): got push %s %c %v, want %s %c %v", s, gotSrc, gotPiece.Byte(), gotPush, src, piece.Byte(), push)
+			}
+			if got.CanPass() != p.CanPass() {
+				t.Errorf("ParsePosition(%q): got can_pass=%v, want %v", s, got.CanPass(), p.CanPass())
+			}
+			for _, h := range p.turnHash {
+				if got.threefold.Lookup(h) != p.threefold.Lookup(h) {
+					t.Errorf("ParsePosition(%q): got %d repetitions of %x, want %d", s, got.threefold.Lookup(h), h, p.threefold.Lookup(h))
+				}
+			}
+		})
+	}
+}
+
+func TestParseFullPositionErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		s    string
+	}{
+		{"short notation", "g [rrrrrrrrhdcemcdh                                HDCMECDHRRRRRRRR]"},
+		{"move number", "0g [rrrrrrrrhdcemcdh                                HDCMECDHRRRRRRRR] - -"},
+		{"bad step", "2g [rrrrrrrrhdcemcdh                                HDCMECDHRRRRRRRR] Ee2x2 -"},
+		{"steps do not match", "2g [rrrrrrrrhdcemcdh                                HDCMECDHRRRRRRRR] Ee2n -"},
+		{"bad hash", "2g [rrrrrrrrhdcemcdh                                HDCMECDHRRRRRRRR] - xyz"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ParseFullPosition(tc.s); err == nil {
+				t.Errorf("ParseFullPosition(%q): got nil error, want error", tc.s)
+			}
+		})
+	}
+}
+
+func TestSetPositionFullNotation(t *testing.T) {
+	engine := newTestEngine(t, 0)
+	const s = "4g [rrrrrrrrhdcemcdh                            E   HDCM CDHRRRRRRRR] Ee2n 3e9a948cc04bbcae,dbe995097e3e64b1,cafc989d407fed53,2f8f9918fe0a354c"
+	if err := engine.ExecuteCommand("setposition " + s); err != nil {
+		t.Fatal(err)
+	}
+	if got := engine.FullString(); got != s {
+		t.Errorf("setposition %s: got position %q", s, got)
+	}
+	if got := engine.MoveNum(); got != 4 {
+		t.Errorf("setposition %s: got move number %d, want 4", s, got)
+	}
+}
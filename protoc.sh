@@ -1 +1 @@
-protoc --proto_path proto/ --go_out $GOPATH/src --python_out tensorflow proto/*.proto
\ No newline at end of file
+protoc --proto_path proto/ --go_out . --go_opt module=github.com/ajzaff/bot_zoo --python_out tensorflow proto/*.proto
@@ -0,0 +1,390 @@
+package zoo
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sync"
+)
+
+// ResNet implements the bot_alpha_zoo network defined in alpha/model.py in pure Go.
+// It has an input convolution, a tower of residual blocks and value and policy heads.
+// Batch normalization is folded into the convolution weights when loading.
+// The input convolution is unpadded as in Keras so the tower and heads see a smaller board.
+type ResNet struct {
+	board        int // board width after the input convolution.
+	input        convLayer
+	tower        []residualBlock
+	value        convLayer
+	valueHidden  denseLayer
+	valueOut     denseLayer
+	policy       convLayer
+	policyHidden denseLayer
+	policyOut    denseLayer
+}
+
+type residualBlock struct {
+	conv1 convLayer
+	conv2 convLayer
+}
+
+// convLayer is a convolution followed by batch normalization.
+type convLayer struct {
+	size int       // kernel size.
+	pad  int       // zero padding on each side; size/2 for same padding and 0 for valid.
+	in   int       // input channels.
+	out  int       // output channels.
+	w    []float32 // kernel [size][size][in][out].
+	b    []float32 // bias [out].
+}
+
+type denseLayer struct {
+	in  int
+	out int
+	w   []float32 // kernel [in][out].
+	b   []float32 // bias [out].
+}
+
+// batchNormEpsilon is the Keras BatchNormalization default.
+const batchNormEpsilon = 1e-3
+
+// Weights file header.
+const (
+	weightsMagic   = "ZOOW"
+	weightsVersion = 1
+)
+
+// LoadResNet loads the ResNet weights file at path.
+// The file is written by alpha/export_weights.py.
+func LoadResNet(path string) (*ResNet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadResNet(bufio.NewReader(f))
+}
+
+// ReadResNet reads the ResNet weights from r.
+//
+// The format is little endian: the magic "ZOOW", a uint32 version and uint32 tensor count
+// followed by the tensors. Each tensor is a uint32 name length and name, uint32 number of
+// dims and the dims as uint32s followed by the float32 data in row major order.
+func ReadResNet(r io.Reader) (*ResNet, error) {
+	ts, err := readWeights(r)
+	if err != nil {
+		return nil, err
+	}
+	n := &ResNet{}
+	if n.input, err = ts.conv("input_/", 3, featurePlanes, false); err != nil {
+		return nil, err
+	}
+	n.board = n.input.outWidth(8)
+	filters := n.input.out
+	for i := 0; ; i++ {
+		prefix := fmt.Sprintf("res_%d/", i)
+		if _, ok := ts[prefix+"conv1/conv/kernel"]; !ok {
+			break
+		}
+		var b residualBlock
+		if b.conv1, err = ts.conv(prefix+"conv1/", 3, filters, true); err != nil {
+			return nil, err
+		}
+		if b.conv2, err = ts.conv(prefix+"conv2/", 3, filters, true); err != nil {
+			return nil, err
+		}
+		if b.conv2.out != filters {
+			return nil, fmt.Errorf("%s: got %d filters, want %d", prefix, b.conv2.out, filters)
+		}
+		n.tower = append(n.tower, b)
+	}
+	squares := n.board * n.board
+	if n.value, err = ts.conv("value_/", 1, filters, false); err != nil {
+		return nil, err
+	}
+	if n.valueHidden, err = ts.dense("value_/hidden/", squares*n.value.out); err != nil {
+		return nil, err
+	}
+	if n.valueOut, err = ts.dense("value_/value_dense/", n.valueHidden.out); err != nil {
+		return nil, err
+	}
+	if n.valueOut.out != 1 {
+		return nil, fmt.Errorf("value_/value_dense: got %d outputs, want 1", n.valueOut.out)
+	}
+	if n.policy, err = ts.conv("policy_/", 1, filters, false); err != nil {
+		return nil, err
+	}
+	if n.policyHidden, err = ts.dense("policy_/dense/", squares*n.policy.out); err != nil {
+		return nil, err
+	}
+	if n.policyOut, err = ts.dense("policy_/policy_output/", n.policyHidden.out); err != nil {
+		return nil, err
+	}
+	if n.policyOut.out != modelOutputPolicySize {
+		return nil, fmt.Errorf("policy_/policy_output: got %d outputs, want %d", n.policyOut.out, modelOutputPolicySize)
+	}
+	return n, nil
+}
+
+// weightTensor is a named tensor read from a weights file.
+type weightTensor struct {
+	dims []int
+	data []float32
+}
+
+type weightTensors map[string]weightTensor
+
+func readWeights(r io.Reader) (weightTensors, error) {
+	magic := make([]byte, len(weightsMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, fmt.Errorf("read weights header: %v", err)
+	}
+	if string(magic) != weightsMagic {
+		return nil, fmt.Errorf("bad weights magic: %q", magic)
+	}
+	var header [2]uint32
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, fmt.Errorf("read weights header: %v", err)
+	}
+	if header[0] != weightsVersion {
+		return nil, fmt.Errorf("unsupported weights version: %d", header[0])
+	}
+	ts := make(weightTensors, header[1])
+	for i := uint32(0); i < header[1]; i++ {
+		var n uint32
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return nil, fmt.Errorf("read tensor %d: %v", i, err)
+		}
+		name := make([]byte, n)
+		if _, err := io.ReadFull(r, name); err != nil {
+			return nil, fmt.Errorf("read tensor %d: %v", i, err)
+		}
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return nil, fmt.Errorf("read tensor %q: %v", name, err)
+		}
+		dims := make([]uint32, n)
+		if err := binary.Read(r, binary.LittleEndian, dims); err != nil {
+			return nil, fmt.Errorf("read tensor %q: %v", name, err)
+		}
+		t := weightTensor{dims: make([]int, n)}
+		size := 1
+		for j, d := range dims {
+			t.dims[j] = int(d)
+			size *= int(d)
+		}
+		t.data = make([]float32, size)
+		if err := binary.Read(r, binary.LittleEndian, t.data); err != nil {
+			return nil, fmt.Errorf("read tensor %q: %v", name, err)
+		}
+		ts[string(name)] = t
+	}
+	return ts, nil
+}
+
+// get returns the named tensor after checking its shape.
+// Negative dims in shape match any size.
+func (ts weightTensors) get(name string, shape ...int) (weightTensor, error) {
+	t, ok := ts[name]
+	if !ok {
+		return weightTensor{}, fmt.Errorf("missing tensor %q", name)
+	}
+	if len(t.dims) != len(shape) {
+		return weightTensor{}, fmt.Errorf("tensor %q: got shape %v, want %v", name, t.dims, shape)
+	}
+	for i, d := range shape {
+		if d >= 0 && t.dims[i] != d {
+			return weightTensor{}, fmt.Errorf("tensor %q: got shape %v, want %v", name, t.dims, shape)
+		}
+	}
+	return t, nil
+}
+
+// conv loads the convolution and batch normalization under prefix and folds them together.
+// The convolution uses same padding if same is set and valid padding otherwise.
+func (ts weightTensors) conv(prefix string, size, in int, same bool) (convLayer, error) {
+	kernel, err := ts.get(prefix+"conv/kernel", size, size, in, -1)
+	if err != nil {
+		return convLayer{}, err
+	}
+	out := kernel.dims[3]
+	var norm [5]weightTensor
+	for i, name := range []string{"conv/bias", "norm/gamma", "norm/beta", "norm/moving_mean", "norm/moving_variance"} {
+		if norm[i], err = ts.get(prefix+name, out); err != nil {
+			return convLayer{}, err
+		}
+	}
+	bias, gamma, beta, mean, variance := norm[0].data, norm[1].data, norm[2].data, norm[3].data, norm[4].data
+	l := convLayer{
+		size: size,
+		in:   in,
+		out:  out,
+		w:    make([]float32, len(kernel.data)),
+		b:    make([]float32, out),
+	}
+	if same {
+		l.pad = size / 2
+	}
+	for o := 0; o < out; o++ {
+		scale := gamma[o] / float32(math.Sqrt(float64(variance[o])+batchNormEpsilon))
+		l.b[o] = (bias[o]-mean[o])*scale + beta[o]
+		for i := o; i < len(kernel.data); i += out {
+			l.w[i] = kernel.data[i] * scale
+		}
+	}
+	return l, nil
+}
+
+// dense loads the dense layer under prefix.
+func (ts weightTensors) dense(prefix string, in int) (denseLayer, error) {
+	kernel, err := ts.get(prefix+"kernel", in, -1)
+	if err != nil {
+		return denseLayer{}, err
+	}
+	out := kernel.dims[1]
+	bias, err := ts.get(prefix+"bias", out)
+	if err != nil {
+		return denseLayer{}, err
+	}
+	return denseLayer{in: in, out: out, w: kernel.data, b: bias.data}, nil
+}
+
+// axpy computes y += a*x.
+func axpy(y, x []float32, a float32) {
+	x = x[:len(y)]
+	for i := range y {
+		y[i] += a * x[i]
+	}
+}
+
+func relu(x []float32) {
+	for i, v := range x {
+		if v < 0 {
+			x[i] = 0
+		}
+	}
+}
+
+// outWidth returns the width of the output of the convolution over a board of width n.
+func (l *convLayer) outWidth(n int) int {
+	return n + 2*l.pad - l.size + 1
+}
+
+// forward computes the convolution of x over a board of width n into y.
+// Activations are stored in channels last order [rank][file][channel].
+func (l *convLayer) forward(x, y []float32, n int) {
+	m := l.outWidth(n)
+	for sq := 0; sq < m*m; sq++ {
+		out := y[sq*l.out : (sq+1)*l.out]
+		copy(out, l.b)
+		r, f := sq/m, sq%m
+		for dr := 0; dr < l.size; dr++ {
+			rr := r + dr - l.pad
+			if rr < 0 || rr >= n {
+				continue
+			}
+			for df := 0; df < l.size; df++ {
+				ff := f + df - l.pad
+				if ff < 0 || ff >= n {
+					continue
+				}
+				in := x[(rr*n+ff)*l.in : (rr*n+ff+1)*l.in]
+				w := l.w[(dr*l.size+df)*l.in*l.out:]
+				for i, v := range in {
+					if v != 0 {
+						axpy(out, w[i*l.out:(i+1)*l.out], v)
+					}
+				}
+			}
+		}
+	}
+}
+
+// forward computes the dense layer of x into y.
+func (l *denseLayer) forward(x, y []float32) {
+	copy(y, l.b)
+	for i, v := range x[:l.in] {
+		if v != 0 {
+			axpy(y[:l.out], l.w[i*l.out:(i+1)*l.out], v)
+		}
+	}
+}
+
+// Evaluate runs the network on the dense input features.
+func (n *ResNet) Evaluate(input [][][]float32) (value float32, policy []float32) {
+	filters := n.input.out
+	x := make([]float32, 64*featurePlanes)
+	for r, rank := range input {
+		for f, planes := range rank {
+			copy(x[(r*8+f)*featurePlanes:], planes)
+		}
+	}
+	squares := n.board * n.board
+	y := make([]float32, squares*filters)
+	n.input.forward(x, y, 8)
+	relu(y)
+
+	// Residual tower.
+	x, y = y, make([]float32, squares*filters)
+	tmp := make([]float32, squares*filters)
+	for i := range n.tower {
+		b := &n.tower[i]
+		b.conv1.forward(x, tmp, n.board)
+		relu(tmp)
+		b.conv2.forward(tmp, y, n.board)
+		for j := range y {
+			y[j] += x[j]
+		}
+		relu(y)
+		x, y = y, x
+	}
+
+	// Value head.
+	head := make([]float32, squares*n.value.out)
+	n.value.forward(x, head, n.board)
+	relu(head)
+	hidden := make([]float32, n.valueHidden.out)
+	n.valueHidden.forward(head, hidden)
+	relu(hidden)
+	var out [1]float32
+	n.valueOut.forward(hidden, out[:])
+	value = float32(math.Tanh(float64(out[0])))
+
+	// Policy head.
+	head = make([]float32, squares*n.policy.out)
+	n.policy.forward(x, head, n.board)
+	relu(head)
+	hidden = make([]float32, n.policyHidden.out)
+	n.policyHidden.forward(head, hidden)
+	relu(hidden)
+	policy = make([]float32, n.policyOut.out)
+	n.policyOut.forward(hidden, policy)
+	return value, policy
+}
+
+// EvaluateBatch evaluates the positions concurrently.
+func (n *ResNet) EvaluateBatch(ps []*Pos) (values []float32, policies [][]float32) {
+	values = make([]float32, len(ps))
+	policies = make([][]float32, len(ps))
+	var wg sync.WaitGroup
+	for i, p := range ps {
+		wg.Add(1)
+		go func(i int, p *Pos) {
+			defer wg.Done()
+			input := newDenseFeatures()
+			DenseFeatures(p, input)
+			values[i], policies[i] = n.Evaluate(input)
+		}(i, p)
+	}
+	wg.Wait()
+	return values, policies
+}
+
+// SetSeed is a noop for the ResNet.
+// Provided for ModelInterface.
+func (n *ResNet) SetSeed(seed int64) {}
+
+// Close is a noop for the ResNet.
+func (n *ResNet) Close() error { return nil }
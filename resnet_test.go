@@ -0,0 +1,397 @@
+package zoo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type namedTensor struct {
+	name string
+	dims []int
+	data []float32
+}
+
+// writeWeights writes the tensors in the format read by ReadResNet.
+func writeWeights(ts []namedTensor) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(weightsMagic)
+	binary.Write(&buf, binary.LittleEndian, [2]uint32{weightsVersion, uint32(len(ts))})
+	for _, t := range ts {
+		binary.Write(&buf, binary.LittleEndian, uint32(len(t.name)))
+		buf.WriteString(t.name)
+		binary.Write(&buf, binary.LittleEndian, uint32(len(t.dims)))
+		for _, d := range t.dims {
+			binary.Write(&buf, binary.LittleEndian, uint32(d))
+		}
+		binary.Write(&buf, binary.LittleEndian, t.data)
+	}
+	return buf.Bytes()
+}
+
+// identityNorm returns batch normalization tensors which scale by gamma.
+func identityNorm(prefix string, gamma []float32) []namedTensor {
+	n := len(gamma)
+	variance := make([]float32, n)
+	for i := range variance {
+		variance[i] = 1 - batchNormEpsilon
+	}
+	return []namedTensor{
+		{prefix + "conv/bias", []int{n}, make([]float32, n)},
+		{prefix + "norm/gamma", []int{n}, gamma},
+		{prefix + "norm/beta", []int{n}, make([]float32, n)},
+		{prefix + "norm/moving_mean", []int{n}, make([]float32, n)},
+		{prefix + "norm/moving_variance", []int{n}, variance},
+	}
+}
+
+func randomTensor(r *rand.Rand, name string, dims ...int) namedTensor {
+	size := 1
+	for _, d := range dims {
+		size *= d
+	}
+	data := make([]float32, size)
+	for i := range data {
+		data[i] = float32(r.NormFloat64() * 0.1)
+	}
+	return namedTensor{name, dims, data}
+}
+
+func randomConvTensors(r *rand.Rand, prefix string, size, in, out int) []namedTensor {
+	variance := randomTensor(r, prefix+"norm/moving_variance", out)
+	for i, v := range variance.data {
+		variance.data[i] = 1 + float32(math.Abs(float64(v)))
+	}
+	return []namedTensor{
+		randomTensor(r, prefix+"conv/kernel", size, size, in, out),
+		randomTensor(r, prefix+"conv/bias", out),
+		randomTensor(r, prefix+"norm/gamma", out),
+		randomTensor(r, prefix+"norm/beta", out),
+		randomTensor(r, prefix+"norm/moving_mean", out),
+		variance,
+	}
+}
+
+// randomResNetWeights returns random weights for a ResNet with the given depth and filters.
+func randomResNetWeights(seed int64, depth, filters int) []namedTensor {
+	r := rand.New(rand.NewSource(seed))
+	ts := randomConvTensors(r, "input_/", 3, featurePlanes, filters)
+	for i := 0; i < depth; i++ {
+		prefix := fmt.Sprintf("res_%d/", i)
+		ts = append(ts, randomConvTensors(r, prefix+"conv1/", 3, filters, filters)...)
+		ts = append(ts, randomConvTensors(r, prefix+"conv2/", 3, filters, filters)...)
+	}
+	ts = append(ts, randomConvTensors(r, "value_/", 1, filters, 1)...)
+	ts = append(ts,
+		randomTensor(r, "value_/hidden/kernel", 36, 16),
+		randomTensor(r, "value_/hidden/bias", 16),
+		randomTensor(r, "value_/value_dense/kernel", 16, 1),
+		randomTensor(r, "value_/value_dense/bias", 1))
+	ts = append(ts, randomConvTensors(r, "policy_/", 1, filters, 2)...)
+	ts = append(ts,
+		randomTensor(r, "policy_/dense/kernel", 72, 16),
+		randomTensor(r, "policy_/dense/bias", 16),
+		randomTensor(r, "policy_/policy_output/kernel", 16, modelOutputPolicySize),
+		randomTensor(r, "policy_/policy_output/bias", modelOutputPolicySize))
+	return ts
+}
+
+func TestReadResNet(t *testing.T) {
+	n, err := ReadResNet(bytes.NewReader(writeWeights(randomResNetWeights(1, 2, 8))))
+	if err != nil {
+		t.Fatalf("ReadResNet(): %v", err)
+	}
+	if got, want := len(n.tower), 2; got != want {
+		t.Fatalf("ReadResNet(): got depth %d, want %d", got, want)
+	}
+	p, err := ParseShortPosition("g [rrrrrrrrhdcemcdh                                HDCMECDHRRRRRRRR]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, policies := n.EvaluateBatch([]*Pos{p, p})
+	if len(policies[0]) != modelOutputPolicySize {
+		t.Fatalf("EvaluateBatch(): got policy size %d, want %d", len(policies[0]), modelOutputPolicySize)
+	}
+	if values[0] < -1 || values[0] > 1 {
+		t.Errorf("EvaluateBatch(): got value %v, want in [-1, 1]", values[0])
+	}
+	if values[0] != values[1] {
+		t.Errorf("EvaluateBatch(): got different values %v and %v for the same position", values[0], values[1])
+	}
+	for i := range policies[0] {
+		if policies[0][i] != policies[1][i] {
+			t.Fatalf("EvaluateBatch(): got different policies for the same position")
+		}
+	}
+}
+
+func TestReadResNetErrors(t *testing.T) {
+	weights := randomResNetWeights(1, 1, 4)
+	for _, tc := range []struct {
+		name string
+		data []byte
+	}{{
+		name: "bad magic",
+		data: []byte("ZOOX\x01\x00\x00\x00\x00\x00\x00\x00"),
+	}, {
+		name: "truncated",
+		data: writeWeights(weights)[:100],
+	}, {
+		name: "missing tensor",
+		data: writeWeights(weights[1:]),
+	}, {
+		name: "bad policy size",
+		data: writeWeights(append(weights[:len(weights)-2],
+			namedTensor{"policy_/policy_output/kernel", []int{16, 3}, make([]float32, 48)},
+			namedTensor{"policy_/policy_output/bias", []int{3}, make([]float32, 3)})),
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ReadResNet(bytes.NewReader(tc.data)); err == nil {
+				t.Errorf("ReadResNet(): got nil error, want error")
+			}
+		})
+	}
+}
+
+func TestConvLayerForward(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		// kernel offset of the single nonzero kernel weight.
+		dr, df int
+		gamma  float32
+		// input square and wanted output square.
+		in, want Square
+	}{{
+		name:  "identity",
+		dr:    1,
+		df:    1,
+		gamma: 1,
+		in:    D4,
+		want:  D4,
+	}, {
+		name:  "shift",
+		dr:    0,
+		df:    0,
+		gamma: 1,
+		in:    D4,
+		want:  E5,
+	}, {
+		name:  "scale",
+		dr:    1,
+		df:    1,
+		gamma: 2,
+		in:    A1,
+		want:  A1,
+	}, {
+		name:  "padding",
+		dr:    2,
+		df:    2,
+		gamma: 1,
+		in:    H8,
+		want:  G7,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			kernel := make([]float32, 9)
+			kernel[tc.dr*3+tc.df] = 1
+			ts := weightTensors{}
+			for _, nt := range append(identityNorm("", []float32{tc.gamma}),
+				namedTensor{"conv/kernel", []int{3, 3, 1, 1}, kernel}) {
+				ts[nt.name] = weightTensor{nt.dims, nt.data}
+			}
+			l, err := ts.conv("", 3, 1, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			x, y := make([]float32, 64), make([]float32, 64)
+			x[tc.in] = 1
+			l.forward(x, y, 8)
+			for sq := Square(0); sq < 64; sq++ {
+				want := float32(0)
+				if sq == tc.want {
+					want = tc.gamma
+				}
+				if got := y[sq]; math.Abs(float64(got-want)) > 1e-6 {
+					t.Errorf("forward(): got %v at %s, want %v", got, sq, want)
+				}
+			}
+		})
+	}
+}
+
+func TestConvLayerValid(t *testing.T) {
+	kernel := make([]float32, 9)
+	kernel[2*3+1] = 1
+	ts := weightTensors{}
+	for _, nt := range append(identityNorm("", []float32{1}),
+		namedTensor{"conv/kernel", []int{3, 3, 1, 1}, kernel}) {
+		ts[nt.name] = weightTensor{nt.dims, nt.data}
+	}
+	l, err := ts.conv("", 3, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := l.outWidth(8); got != 6 {
+		t.Fatalf("outWidth(8): got %d, want 6", got)
+	}
+	// Output (r, f) reads input (r+2, f+1) so D4 lands on rank 1 file 2 of the 6x6 output.
+	x, y := make([]float32, 64), make([]float32, 36)
+	x[D4] = 1
+	l.forward(x, y, 8)
+	for i, got := range y {
+		want := float32(0)
+		if i == 1*6+2 {
+			want = 1
+		}
+		if math.Abs(float64(got-want)) > 1e-6 {
+			t.Errorf("forward(): got %v at %d, want %v", got, i, want)
+		}
+	}
+}
+
+func TestConvLayerBatchNorm(t *testing.T) {
+	ts := weightTensors{
+		"conv/kernel":          {[]int{1, 1, 1, 1}, []float32{3}},
+		"conv/bias":            {[]int{1}, []float32{1}},
+		"norm/gamma":           {[]int{1}, []float32{2}},
+		"norm/beta":            {[]int{1}, []float32{0.5}},
+		"norm/moving_mean":     {[]int{1}, []float32{4}},
+		"norm/moving_variance": {[]int{1}, []float32{4 - batchNormEpsilon}},
+	}
+	l, err := ts.conv("", 1, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, y := make([]float32, 64), make([]float32, 64)
+	x[0] = 2
+	l.forward(x, y, 8)
+	// ((3*2 + 1) - 4) * 2/sqrt(4) + 0.5.
+	if got, want := y[0], float32(3.5); math.Abs(float64(got-want)) > 1e-6 {
+		t.Errorf("forward(): got %v, want %v", got, want)
+	}
+	// (1 - 4) * 2/sqrt(4) + 0.5.
+	if got, want := y[1], float32(-2.5); math.Abs(float64(got-want)) > 1e-6 {
+		t.Errorf("forward(): got %v, want %v", got, want)
+	}
+}
+
+type goldenCase struct {
+	input  [][][]float32
+	value  float64
+	policy []float64
+}
+
+// readGoldenCases reads the inputs and outputs written by alpha/resnet_golden.py.
+// It returns the backend recorded in the header with the cases.
+func readGoldenCases(path string) (backend string, cases []goldenCase, err error) {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+	for i, line := range strings.Split(string(bs), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if strings.HasPrefix(fields[0], "#") {
+			for _, f := range fields {
+				if strings.HasPrefix(f, "--backend=") {
+					backend = strings.TrimSuffix(strings.TrimPrefix(f, "--backend="), ".")
+				}
+			}
+			continue
+		}
+		if fields[0] == "input" {
+			if len(fields) != 2 || len(fields[1]) != 64*featurePlanes {
+				return "", nil, fmt.Errorf("line %d: bad input", i+1)
+			}
+			c := goldenCase{input: newDenseFeatures()}
+			for j, b := range fields[1] {
+				if b == '1' {
+					sq := j / featurePlanes
+					c.input[sq/8][sq%8][j%featurePlanes] = 1
+				}
+			}
+			cases = append(cases, c)
+			continue
+		}
+		if len(cases) == 0 {
+			return "", nil, fmt.Errorf("line %d: %s before input", i+1, fields[0])
+		}
+		c := &cases[len(cases)-1]
+		var vs []float64
+		for _, f := range fields[1:] {
+			v, err := strconv.ParseFloat(f, 64)
+			if err != nil {
+				return "", nil, fmt.Errorf("line %d: %v", i+1, err)
+			}
+			vs = append(vs, v)
+		}
+		switch {
+		case fields[0] == "value" && len(vs) == 1:
+			c.value = vs[0]
+		case fields[0] == "policy" && len(vs) == modelOutputPolicySize:
+			c.policy = vs
+		default:
+			return "", nil, fmt.Errorf("line %d: bad %s", i+1, fields[0])
+		}
+	}
+	return backend, cases, nil
+}
+
+// testGoldenResNet compares the ResNet loaded from testdata/name.weights with the outputs
+// in testdata/name.txt which must have been written by the given backend.
+func testGoldenResNet(t *testing.T, name, wantBackend string) {
+	n, err := LoadResNet(filepath.Join("testdata", name+".weights"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend, cases, err := readGoldenCases(filepath.Join("testdata", name+".txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backend != wantBackend {
+		t.Fatalf("readGoldenCases(): got outputs of backend %q, want %q", backend, wantBackend)
+	}
+	if len(cases) == 0 {
+		t.Fatal("readGoldenCases(): got no cases")
+	}
+	// near reports whether got is within float32 precision of want.
+	near := func(got float32, want float64) bool {
+		return math.Abs(float64(got)-want) <= 1e-4*math.Max(1, math.Abs(want))
+	}
+	for i, c := range cases {
+		value, policy := n.Evaluate(c.input)
+		if !near(value, c.value) {
+			t.Errorf("Evaluate(case %d): got value %v, want %v", i, value, c.value)
+		}
+		for j, want := range c.policy {
+			if !near(policy[j], want) {
+				t.Errorf("Evaluate(case %d): got policy[%d] %v, want %v", i, j, policy[j], want)
+				break
+			}
+		}
+	}
+}
+
+// TestResNetGolden compares the ResNet with the Tensorflow bot_alpha_zoo-16 model on the
+// weights and outputs written by alpha/resnet_golden.py --backend=tf.
+func TestResNetGolden(t *testing.T) {
+	if _, err := os.Stat(filepath.Join("testdata", "resnet_golden.weights")); os.IsNotExist(err) {
+		t.Skip("testdata/resnet_golden.weights does not exist; write it with alpha/resnet_golden.py --backend=tf")
+	}
+	testGoldenResNet(t, "resnet_golden", "tf")
+}
+
+// TestResNetReference compares the ResNet with the pure Python layers of
+// alpha/resnet_golden.py --backend=reference on a small network.
+func TestResNetReference(t *testing.T) {
+	testGoldenResNet(t, "resnet_reference", "reference")
+}
@@ -0,0 +1,62 @@
+//go:build tensorflow
+
+package zoo
+
+import (
+	"flag"
+	"math"
+	"testing"
+)
+
+var (
+	goldenGraphPath   = flag.String("golden_graph", "", "Path to the frozen bot_alpha_zoo GraphDef for TestResNetTensorflow")
+	goldenWeightsPath = flag.String("golden_weights", "", "Path to the weights exported alongside -golden_graph for TestResNetTensorflow")
+)
+
+// TestResNetTensorflow compares the pure Go ResNet with the Tensorflow model.
+// Both files are written by alpha/model.py:
+//
+//	go test -tags tensorflow -run ResNetTensorflow -golden_graph bot_alpha_zoo-16.pb -golden_weights bot_alpha_zoo-16.weights
+func TestResNetTensorflow(t *testing.T) {
+	if *goldenGraphPath == "" || *goldenWeightsPath == "" {
+		t.Skip("-golden_graph and -golden_weights are not set")
+	}
+	model, err := NewModel(*goldenGraphPath)
+	if err != nil {
+		t.Fatalf("NewModel(): %v", err)
+	}
+	defer model.Close()
+	resnet, err := LoadResNet(*goldenWeightsPath)
+	if err != nil {
+		t.Fatalf("LoadResNet(): %v", err)
+	}
+
+	var ps []*Pos
+	for _, s := range []string{
+		"g [rrrrrrrrhdcemcdh                                HDCMECDHRRRRRRRR]",
+		"s [rrrrrrrrhdcemcdh                                HDCMECDHRRRRRRRR]",
+		"g [       r                            Ed     Cr           R       ]",
+		"s [          eD       r                                            ]",
+	} {
+		p, err := ParseShortPosition(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ps = append(ps, p)
+	}
+	wantValues, wantPolicies := model.EvaluateBatch(ps)
+	gotValues, gotPolicies := resnet.EvaluateBatch(ps)
+
+	const tolerance = 1e-3
+	for i, p := range ps {
+		if d := math.Abs(float64(gotValues[i] - wantValues[i])); d > tolerance {
+			t.Errorf("EvaluateBatch(%s): got value %v, want %v", p.ShortString(), gotValues[i], wantValues[i])
+		}
+		for j := range wantPolicies[i] {
+			if d := math.Abs(float64(gotPolicies[i][j] - wantPolicies[i][j])); d > tolerance {
+				t.Errorf("EvaluateBatch(%s): got policy[%d] %v, want %v", p.ShortString(), j, gotPolicies[i][j], wantPolicies[i][j])
+				break
+			}
+		}
+	}
+}
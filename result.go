@@ -0,0 +1,47 @@
+package zoo
+
+// Reason is the rule which decided the result of a game.
+type Reason uint8
+
+const (
+	// ReasonNone is the reason for a game which is not over.
+	ReasonNone Reason = iota
+	// ReasonGoal is a rabbit reaching the goal rank.
+	ReasonGoal
+	// ReasonElimination is a player losing all rabbits.
+	ReasonElimination
+	// ReasonImmobilization is a player to move without a legal move.
+	ReasonImmobilization
+	// ReasonRepetition is a move repeating a position for the third time.
+	ReasonRepetition
+	// ReasonNoChange is a move which leaves the position unchanged.
+	ReasonNoChange
+)
+
+var reasonStrings = []string{
+	ReasonNone:           "none",
+	ReasonGoal:           "goal",
+	ReasonElimination:    "elimination",
+	ReasonImmobilization: "immobilization",
+	ReasonRepetition:     "repetition",
+	ReasonNoChange:       "no change",
+}
+
+func (r Reason) String() string {
+	if int(r) < len(reasonStrings) {
+		return reasonStrings[r]
+	}
+	return "unknown"
+}
+
+// Result is the result of a game from the perspective of the side to move.
+// Value is Win or Loss when the game is over and 0 otherwise.
+type Result struct {
+	Value  Value
+	Reason Reason
+}
+
+// Terminal returns whether the game is over.
+func (r Result) Terminal() bool {
+	return r.Value.Terminal()
+}
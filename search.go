@@ -3,6 +3,7 @@ package zoo
 import (
 	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -15,6 +16,8 @@ type searchState struct {
 
 	wg       sync.WaitGroup
 	bestMove Move
+	r        *rand.Rand // random state for move sampling and root noise.
+	selfPlay bool       // playing a self-play game; sample moves and add root noise.
 
 	// semi-atomic
 	stopping int32
@@ -23,10 +26,11 @@ type searchState struct {
 
 // ModelInterface defines an interface for a model.
 type ModelInterface interface {
-	EvaluatePosition(p *Pos)
+	// EvaluateBatch evaluates the positions and returns the value estimate
+	// and policy logits for each position. Values are from the perspective
+	// of the side to move. EvaluateBatch must be safe for concurrent use.
+	EvaluateBatch(ps []*Pos) (values []float32, policies [][]float32)
 	SetSeed(seed int64)
-	Value() float32
-	Policy(policy []float32)
 	Close() error
 }
 
@@ -37,19 +41,58 @@ type BatchWriterInterface interface {
 	Flush() error
 }
 
+// writeExamples writes the examples for the move m played from p to w with the policy of tree t.
+// p is restored afterwards.
+func writeExamples(w BatchWriterInterface, p *Pos, t *Tree, m Move) {
+	writeStepExamples(w, p, m, func(int) *Tree { return t })
+}
+
+// writeStepExamples is like writeExamples but step i of m has the policy of tree(i).
+func writeStepExamples(w BatchWriterInterface, p *Pos, m Move, tree func(i int) *Tree) {
+	if p.MoveNum() == 1 && p.Side() == Gold {
+		w.WriteExample(p, tree(0))
+	}
+	for i, s := range m {
+		p.Step(s)
+		w.WriteExample(p, tree(i))
+	}
+	for range m {
+		p.Unstep()
+	}
+}
+
 func (s *searchState) Reset(settings *EngineSettings) error {
 	if s.tt == nil {
 		s.tt = &TranspositionTable{}
 	}
 	s.tt.Resize(50)
 	s.tree = NewEmptyTree(s.tt)
+	if s.r == nil {
+		seed := settings.Seed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		s.r = rand.New(rand.NewSource(seed))
+	}
 	if s.model == nil {
-		if settings.UseSavedModel {
+		if settings.ModelServerPath != "" {
+			model, err := DialModelServer(settings.ModelServerPath)
+			if err != nil {
+				return err
+			}
+			s.model = model
+		} else if settings.UseSavedModel {
 			model, err := NewModel(settings.SavedModelPath)
 			if err != nil {
 				return err
 			}
 			s.model = model
+		} else if settings.ResNetWeightsPath != "" {
+			model, err := LoadResNet(settings.ResNetWeightsPath)
+			if err != nil {
+				return err
+			}
+			s.model = model
 		} else {
 			s.model = NewDummyModel()
 		}
@@ -60,41 +103,200 @@ func (s *searchState) Reset(settings *EngineSettings) error {
 	return nil
 }
 
-func (e *Engine) searchRoot(ponder bool) {
-	defer e.Stop()
+// searchPlayouts is the number of playouts per search shared by all search goroutines
+// when neither the playouts option nor the game clock limits the search.
+const searchPlayouts = 1600
+
+// searchCheckInterval is the number of playouts between time and stability checks.
+const searchCheckInterval = 100
+
+// searchLimits decides when a search stops.
+// It is shared by all search goroutines.
+type searchLimits struct {
+	playouts int64            // maximum number of playouts or 0 for no limit.
+	timed    bool             // budget applies to this search.
+	solved   bool             // stop once the root is proven.
+	budget   searchBudget     // time budget for this search.
+	now      func() time.Time // clock used for the time budget.
+
+	// atomic
+	started int64 // playouts started.
+	done    int64 // playouts completed.
+	stop    int32 // set when the search should stop.
+
+	m    sync.Mutex // guards budget and best.
+	best *TreeNode  // last node of the best move at the last check.
+}
+
+// newSearchLimits creates the search limits for a search from position p.
+// The playouts option limits the number of playouts. The game clock limits
+// the time unless pondering. Without either, searchPlayouts are run.
+// Only infinite searches continue after the root is proven.
+func (e *Engine) newSearchLimits(p *Pos, mode searchMode) *searchLimits {
+	l := &searchLimits{now: e.now, solved: mode != searchInfinite}
+	if mode != searchNormal {
+		// Search until stopped.
+		return l
+	}
+	if v, ok := e.LookupOption("playouts"); ok {
+		l.playouts = int64(v.(int))
+	}
+	if e.timeInfo != nil {
+		l.budget, l.timed = e.timeControl.newSearchBudget(e.timeInfo, p.Side(), e.now())
+	}
+	if l.playouts <= 0 && !l.timed {
+		l.playouts = searchPlayouts
+	}
+	return l
+}
+
+// next reserves the next playout and returns true if the search should continue.
+func (l *searchLimits) next() bool {
+	if atomic.LoadInt32(&l.stop) != 0 {
+		return false
+	}
+	return l.playouts <= 0 || atomic.AddInt64(&l.started, 1) <= l.playouts
+}
+
+// update records a completed playout and periodically checks the time budget.
+// The search stops as soon as the root is proven.
+// The soft limit is extended whenever the best move changes. The search stops
+// early when the runner up cannot catch the best move in the remaining time.
+func (l *searchLimits) update(t *Tree) {
+	done := atomic.AddInt64(&l.done, 1)
+	if l.solved && t.root.Proof() != 0 {
+		atomic.StoreInt32(&l.stop, 1)
+		return
+	}
+	if !l.timed || done%searchCheckInterval != 0 {
+		return
+	}
+
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	elapsed := l.now().Sub(l.budget.start)
+	if elapsed >= l.budget.maximum {
+		atomic.StoreInt32(&l.stop, 1)
+		return
+	}
+
+	best, gap, ok := t.bestMoveGap()
+	if !ok {
+		return
+	}
+	if l.best != nil && best != l.best {
+		l.budget.extend()
+	}
+	l.best = best
+	if elapsed >= l.budget.optimal {
+		atomic.StoreInt32(&l.stop, 1)
+		return
+	}
+
+	// Estimate the playouts remaining from the rate so far.
+	if elapsed > 0 {
+		remaining := float64(done) * float64(l.budget.optimal-elapsed) / float64(elapsed)
+		if float64(gap) > remaining {
+			atomic.StoreInt32(&l.stop, 1)
+		}
+	}
+}
+
+// goroutines returns the number of goroutines to use for search.
+// The goroutines option takes precedence over the concurrency flag.
+func (e *Engine) goroutines() int {
+	n := int(e.Concurrency)
+	if v, ok := e.LookupOption("goroutines"); ok {
+		n = v.(int)
+	}
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// defaultBatchTimeout is the default time to wait for a batch of leaves to fill.
+const defaultBatchTimeout = time.Millisecond
+
+// newBatchEvaluator creates the evaluator used by the search goroutines.
+// The batchsize option defaults to the number of search goroutines and
+// the batchtimeout option is given in microseconds.
+func (e *Engine) newBatchEvaluator() *BatchEvaluator {
+	size := e.goroutines()
+	if v, ok := e.LookupOption("batchsize"); ok && v.(int) > 0 {
+		size = v.(int)
+	}
+	timeout := defaultBatchTimeout
+	if v, ok := e.LookupOption("batchtimeout"); ok && v.(int) > 0 {
+		timeout = time.Duration(v.(int)) * time.Microsecond
+	}
+	return NewBatchEvaluator(e.model, size, timeout)
+}
+
+// searchWorker runs playouts on the tree until the search limits are reached or the search is stopped.
+func (e *Engine) searchWorker(p *Pos, l *searchLimits, model ModelInterface) {
+	for atomic.LoadInt32(&e.stopping) == 0 && l.next() {
+		n, p := e.tree.Select(p)
+		n.Expand(p, model)
+		l.update(e.tree)
+	}
+}
+
+// searchMode selects the limits and output of a search.
+type searchMode int
+
+const (
+	// searchNormal searches within the time and playout limits and outputs the best move.
+	searchNormal searchMode = iota
+	// searchPonder searches the position until stopped and does not output a best move.
+	searchPonder
+	// searchInfinite searches the position until stopped and outputs the best move.
+	searchInfinite
+)
+
+// searchRoot searches the engine position.
+// The caller must add the search to e.wg.
+func (e *Engine) searchRoot(mode searchMode) {
+	defer func() {
+		atomic.StoreInt32(&e.running, 0)
+		e.wg.Done()
+	}()
 
 	if e.UseTranspositionTable {
 		e.tt.NewSearch()
 	}
 
-	e.wg.Add(1)
-	defer e.wg.Done()
-
 	p := e.Pos.Clone()
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	sample := e.UseSampledMove || e.selfPlay
 
-	e.tree.Reset()
 	e.tree.UpdateRoot(p, e.model)
-	e.tree.SetSample(e.UseSampledMove)
+	e.tree.SetSample(sample)
+	e.tree.SetPUCT(e.GetOption("cpuct").(float64), e.GetOption("fpu").(float64))
+	e.tree.SetTemperature(e.GetOption("temperature").(float64), e.GetOption("temperaturemoves").(int))
+	if sample && mode == searchNormal {
+		e.tree.AddRootNoise(e.r, e.GetOption("dirichletalpha").(float64), e.GetOption("dirichletfraction").(float64))
+	}
 
-	for i := 0; i < 1600; i++ {
-		n, p := e.tree.Select(p)
-		n.Expand(p, e.model)
+	var (
+		wg     sync.WaitGroup
+		limits = e.newSearchLimits(p, mode)
+		model  = e.newBatchEvaluator()
+	)
+	for i := 0; i < e.goroutines(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e.searchWorker(p, limits, model)
+		}()
 	}
+	wg.Wait()
+	model.Close()
 
-	m, value, _, ok := e.tree.BestMove(r)
+	m, value, _, ok := e.tree.BestMove(e.r, e.model)
 
-	if e.UseDatasetWriter {
-		if p.MoveNum() == 1 && p.Side() == Gold {
-			e.batchWriter.WriteExample(p, e.tree)
-		}
-		for _, s := range m {
-			p.Step(s)
-			e.batchWriter.WriteExample(p, e.tree)
-		}
-		for range m {
-			p.Unstep()
-		}
+	if e.UseDatasetWriter && mode == searchNormal {
+		writeExamples(e.batchWriter, p, e.tree, m)
 	}
 
 	if !ok {
@@ -102,11 +304,10 @@ func (e *Engine) searchRoot(ponder bool) {
 		return
 	}
 	e.Logf("info score %f", value)
-	if ponder {
+	if mode == searchPonder {
 		e.Outputf("info pv %s", m)
-	} else {
-		e.Outputf("bestmove %s", m)
+		return
 	}
-
+	e.Outputf("bestmove %s", m)
 	e.bestMove = m
 }
@@ -0,0 +1,127 @@
+package zoo
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// evalCounter counts the positions evaluated by the wrapped model.
+type evalCounter struct {
+	ModelInterface
+	n int64
+}
+
+func (m *evalCounter) EvaluateBatch(ps []*Pos) (values []float32, policies [][]float32) {
+	atomic.AddInt64(&m.n, int64(len(ps)))
+	return m.ModelInterface.EvaluateBatch(ps)
+}
+
+// waitEvals waits until the model evaluated more than n positions.
+func waitEvals(t *testing.T, m *evalCounter, n int64) {
+	t.Helper()
+	deadline := time.Now().Add(30 * time.Second)
+	for atomic.LoadInt64(&m.n) <= n {
+		if time.Now().After(deadline) {
+			t.Fatalf("search stopped after %d evaluations, want more than %d", atomic.LoadInt64(&m.n), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestGoPonder(t *testing.T) {
+	engine := newTestEngine(t, 0)
+	engine.UsePonder = true
+	var out bytes.Buffer
+	engine.out = log.New(&out, "", 0)
+	model := &evalCounter{ModelInterface: engine.model}
+	engine.model = model
+
+	// Ponder runs past the default playout limit until stopped.
+	if err := engine.ExecuteCommand("go ponder"); err != nil {
+		t.Fatal(err)
+	}
+	waitEvals(t, model, searchPlayouts)
+	engine.Stop()
+	if strings.Contains(out.String(), "bestmove") {
+		t.Errorf("go ponder: got output %q, want no bestmove", out.String())
+	}
+
+	// The predicted move keeps the pondered subtree.
+	m, _, n, ok := engine.tree.BestMove(nil, nil)
+	if !ok {
+		t.Fatal("BestMove(): got no best move")
+	}
+	runs := n.Runs()
+	if err := engine.ExecuteCommand("makemove " + m.String()); err != nil {
+		t.Fatal(err)
+	}
+	if root := engine.tree.Root(); root != n || root.Runs() != runs {
+		t.Errorf("makemove %s: got root with %d runs, want the pondered node with %d runs", m, root.Runs(), runs)
+	}
+}
+
+func TestGoPonderDisabled(t *testing.T) {
+	engine := newTestEngine(t, 0)
+	engine.UsePonder = false
+	if err := engine.ExecuteCommand("go ponder"); err != nil {
+		t.Fatal(err)
+	}
+	engine.Stop()
+	if engine.tree.Root() != nil {
+		t.Errorf("go ponder: got search with pondering disabled")
+	}
+}
+
+func TestGoInfinite(t *testing.T) {
+	engine := newTestEngine(t, 100)
+	var out bytes.Buffer
+	engine.out = log.New(&out, "", 0)
+	model := &evalCounter{ModelInterface: engine.model}
+	engine.model = model
+
+	// Infinite search ignores the playouts option.
+	if err := engine.ExecuteCommand("go infinite"); err != nil {
+		t.Fatal(err)
+	}
+	waitEvals(t, model, 200)
+	if err := engine.ExecuteCommand("stop"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "bestmove") {
+		t.Errorf("go infinite: got output %q, want bestmove after stop", out.String())
+	}
+}
+
+// TestGoConcurrent checks the tree after a search on several goroutines.
+// Run it with -race to check the tree updates for data races.
+func TestGoConcurrent(t *testing.T) {
+	const playouts = 800
+	engine := newTestEngine(t, playouts)
+	if err := engine.ExecuteSetOption("name goroutines value 8"); err != nil {
+		t.Fatal(err)
+	}
+	engine.GoWait()
+	if engine.bestMove == nil {
+		t.Fatal("GoWait(): got no best move")
+	}
+	root := engine.tree.Root()
+	// Expanding the new root adds one run.
+	if got := root.Runs(); got != playouts+1 {
+		t.Errorf("GoWait(): got %d root runs, want %d", got, playouts+1)
+	}
+	// Backprop removes the virtual loss of every finished playout.
+	var walk func(n *TreeNode)
+	walk = func(n *TreeNode) {
+		if vl := atomic.LoadInt32(&n.virtualLoss); vl != 0 {
+			t.Errorf("GoWait(): got virtual loss %d after the search, want 0", vl)
+		}
+		for _, child := range n.children {
+			walk(child)
+		}
+	}
+	walk(root)
+}
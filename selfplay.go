@@ -0,0 +1,169 @@
+package zoo
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// selfPlayMaxTurns is the turn limit of self-play games.
+const selfPlayMaxTurns = 600
+
+// playSelfPlayGame plays a new game of the engine against itself until the game ends or maxTurns
+// turns were played. It returns the result for the side to move and false if the game reached
+// the turn limit. Moves are only sampled if selfPlay is set.
+func (e *Engine) playSelfPlayGame(maxTurns int) (result Value, ok bool) {
+	e.NewGame()
+	// Self-play games are not played on a clock.
+	e.timeInfo = nil
+	for i := 0; i < maxTurns; i++ {
+		if result = e.Terminal().Value; result.Terminal() {
+			return result, true
+		}
+		e.GoWait()
+		e.MakeMove(e.bestMove)
+	}
+	result = e.Terminal().Value
+	return result, result.Terminal()
+}
+
+// finishSelfPlayGame records the game played by playSelfPlayGame with the engine's BatchWriter.
+// Games which reached the turn limit are recorded without a winner.
+func (e *Engine) finishSelfPlayGame(result Value, ok bool) error {
+	if !ok {
+		result = 0
+	}
+	return e.batchWriter.Finalize(e.Pos, result)
+}
+
+// SelfPlayStats reports the progress of a SelfPlayPool.
+type SelfPlayStats struct {
+	Games   int           // games finished.
+	Elapsed time.Duration // time since the pool started playing.
+}
+
+// GamesPerHour returns the throughput of the pool.
+func (s SelfPlayStats) GamesPerHour() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Games) / s.Elapsed.Hours()
+}
+
+// SelfPlayPool plays self-play games on several engines in parallel.
+// Each engine plays one game at a time and records it with its own BatchWriter.
+type SelfPlayPool struct {
+	Engines  []*Engine
+	MaxTurns int                       // turns after which a game is recorded without a winner or 0 for the default.
+	Progress func(stats SelfPlayStats) // called after each finished game if not nil.
+}
+
+// NewSelfPlayPool creates a pool of workers engines configured by settings. The engines share
+// one BatchEvaluator over model so that positions from all games are evaluated in batches of
+// up to batchSize. Each engine writes to a BatchWriter shard named after runID and its index.
+// Engines are seeded from seed.
+func NewSelfPlayPool(settings *EngineSettings, aeiSettings *AEISettings, model ModelInterface, workers, batchSize int, runID string, seed int64) (*SelfPlayPool, error) {
+	if workers < 1 {
+		return nil, fmt.Errorf("self-play needs at least one worker")
+	}
+	shared := NewBatchEvaluator(model, batchSize, defaultBatchTimeout)
+	discard := log.New(ioutil.Discard, "", 0)
+	pool := &SelfPlayPool{}
+	for i := 0; i < workers; i++ {
+		s := *settings
+		s.UseDatasetWriter = false
+		s.Seed = seed + int64(i)
+		e, err := newEngine(&s, aeiSettings, shared)
+		if err != nil {
+			pool.Close()
+			return nil, err
+		}
+		w, err := NewShardedBatchWriter(s.DatasetDir, s.DatasetEpoch, runID, strconv.Itoa(i))
+		if err != nil {
+			pool.Close()
+			return nil, err
+		}
+		// Workers always record their games to their own shard.
+		e.UseDatasetWriter = true
+		e.batchWriter = w
+		e.selfPlay = true
+		e.log, e.out = discard, discard
+		pool.Engines = append(pool.Engines, e)
+	}
+	return pool, nil
+}
+
+// Play plays the given number of games on the engines of the pool and flushes their writers.
+// It returns the stats when all games are finished or the first error of a writer.
+func (pool *SelfPlayPool) Play(games int) (SelfPlayStats, error) {
+	maxTurns := pool.MaxTurns
+	if maxTurns == 0 {
+		maxTurns = selfPlayMaxTurns
+	}
+	var (
+		m       sync.Mutex // guards below.
+		started int
+		stats   SelfPlayStats
+		err     error
+	)
+	start := time.Now()
+	// next returns true if the worker should start another game.
+	next := func() bool {
+		m.Lock()
+		defer m.Unlock()
+		if started >= games || err != nil {
+			return false
+		}
+		started++
+		return true
+	}
+	// finish records a finished game and its error if any.
+	finish := func(err1 error) {
+		m.Lock()
+		defer m.Unlock()
+		if err1 != nil {
+			if err == nil {
+				err = err1
+			}
+			return
+		}
+		stats.Games++
+		stats.Elapsed = time.Since(start)
+		if pool.Progress != nil {
+			pool.Progress(stats)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, e := range pool.Engines {
+		wg.Add(1)
+		go func(e *Engine) {
+			defer wg.Done()
+			for next() {
+				finish(e.finishSelfPlayGame(e.playSelfPlayGame(maxTurns)))
+			}
+			if err := e.batchWriter.Flush(); err != nil {
+				finish(err)
+			}
+		}(e)
+	}
+	wg.Wait()
+	stats.Elapsed = time.Since(start)
+	return stats, err
+}
+
+// Close closes the BatchWriters of the engines. The shared model is not closed.
+func (pool *SelfPlayPool) Close() (err error) {
+	for _, e := range pool.Engines {
+		if w, ok := e.batchWriter.(io.Closer); ok {
+			if err1 := w.Close(); err1 != nil && err == nil {
+				err = err1
+			}
+		}
+	}
+	return err
+}
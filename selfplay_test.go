@@ -0,0 +1,141 @@
+package zoo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSelfPlayPool(t *testing.T) {
+	settings := &EngineSettings{DatasetDir: t.TempDir(), Options: SetoptionFlag{{Name: "playouts", StrVal: "10"}}}
+	model := &evalCounter{ModelInterface: NewDummyModel()}
+	pool, err := NewSelfPlayPool(settings, &AEISettings{}, model, 3, 8, "test", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.MaxTurns = 6
+	recorders := make([]*gameRecorder, len(pool.Engines))
+	for i, e := range pool.Engines {
+		if e.model != pool.Engines[0].model {
+			t.Errorf("NewSelfPlayPool(): engine %d does not share the evaluator", i)
+		}
+		e.batchWriter.(*BatchWriter).Close()
+		recorders[i] = &gameRecorder{}
+		e.batchWriter = recorders[i]
+	}
+	var progress []int
+	pool.Progress = func(s SelfPlayStats) { progress = append(progress, s.Games) }
+
+	stats, err := pool.Play(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Games != 7 || len(progress) != 7 || progress[6] != 7 {
+		t.Errorf("Play(7): got %d games and progress %v, want 7 games", stats.Games, progress)
+	}
+	if stats.GamesPerHour() <= 0 {
+		t.Errorf("Play(7): got %v games/hour, want positive", stats.GamesPerHour())
+	}
+	var games int
+	for i, w := range recorders {
+		games += len(w.games)
+		if !w.flushed {
+			t.Errorf("Play(7): writer %d not flushed", i)
+		}
+	}
+	if games != 7 {
+		t.Errorf("Play(7): got %d games recorded, want 7", games)
+	}
+	if model.n == 0 {
+		t.Errorf("Play(7): got no positions evaluated by the shared model")
+	}
+}
+
+func TestShardedBatchWriterNames(t *testing.T) {
+	dir := t.TempDir()
+	a, err := NewShardedBatchWriter(dir, 0, "run1", "0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	b, err := NewShardedBatchWriter(dir, 0, "run1", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if a.name == b.name || !strings.Contains(a.name, "run1_0") {
+		t.Errorf("NewShardedBatchWriter(): got names %q and %q, want distinct names with the shard", a.name, b.name)
+	}
+}
+
+func TestSelfPlayStats(t *testing.T) {
+	s := SelfPlayStats{Games: 30, Elapsed: 30 * time.Minute}
+	if got := s.GamesPerHour(); got != 60 {
+		t.Errorf("GamesPerHour(): got %v, want 60", got)
+	}
+}
+
+func TestSelfPlayPoolTurnLimit(t *testing.T) {
+	dir := t.TempDir()
+	settings := &EngineSettings{DatasetDir: dir, Options: SetoptionFlag{{Name: "playouts", StrVal: "10"}}}
+	pool, err := NewSelfPlayPool(settings, &AEISettings{}, NewDummyModel(), 1, 8, "test", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	// No game ends after both setups and one move of gold.
+	pool.MaxTurns = 3
+	if _, err := pool.Play(3); err != nil {
+		t.Fatal(err)
+	}
+	m := readBatch(t, filepath.Join(dir, "games_e0_test_0_0.pb.snappy"))
+	if len(m.Games) != 3 {
+		t.Fatalf("Play(3): got %d games written, want 3", len(m.Games))
+	}
+	for i, g := range m.Games {
+		if g.Pgn.Result != 0 {
+			t.Errorf("Play(3): got result %d for game %d, want 0", g.Pgn.Result, i)
+		}
+		l, err := ParseMoveList(g.Pgn.Pgn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(l) != 3 {
+			t.Errorf("Play(3): got %d turns in game %d, want 3", len(l), i)
+		}
+		// One example for the empty board and one after each step.
+		examples := 1
+		for _, move := range l {
+			examples += len(move)
+		}
+		if got := len(g.Pgn.Annotations); got != examples {
+			t.Errorf("Play(3): got %d examples for game %d, want %d", got, i, examples)
+		}
+	}
+}
+
+func TestNewSelfPlayPoolError(t *testing.T) {
+	fds, err := ioutil.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skipf("open files cannot be counted: %v", err)
+	}
+	dir := t.TempDir()
+	// The game log of the second shard cannot be opened.
+	if err := os.Mkdir(filepath.Join(dir, "games_e0_test_1.log"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	settings := &EngineSettings{DatasetDir: dir}
+	if _, err := NewSelfPlayPool(settings, &AEISettings{}, NewDummyModel(), 3, 8, "test", 1); err == nil {
+		t.Fatalf("NewSelfPlayPool(): got nil error, want error")
+	}
+	after, err := ioutil.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) != len(fds) {
+		t.Errorf("NewSelfPlayPool(): got %d open files after the error, want %d", len(after), len(fds))
+	}
+}
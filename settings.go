@@ -40,10 +40,15 @@ type EngineSettings struct {
 	Concurrency           uint
 	UseDatasetWriter      bool
 	DatasetEpoch          int
+	DatasetDir            string
+	DatasetRunID          string
 	PlayBatchGames        int
 	UseSampledMove        bool
 	UseSavedModel         bool
 	SavedModelPath        string
+	ResNetWeightsPath     string
+	ModelServerPath       string
+	TimeControl           string
 	Options               SetoptionFlag
 }
 
@@ -72,8 +77,14 @@ func makeSetoptionElem(s string) (e SetoptionElem, err error) {
 	return e, nil
 }
 
+// SetOptionExecutor executes AEI setoption commands.
+// It is implemented by Options and Engine.
+type SetOptionExecutor interface {
+	ExecuteSetOption(s string) error
+}
+
 // Execute executes setoption on o.
-func (e SetoptionElem) Execute(o *Options) error {
+func (e SetoptionElem) Execute(o SetOptionExecutor) error {
 	return o.ExecuteSetOption(fmt.Sprintf("name %s value %s", e.Name, e.StrVal))
 }
 
@@ -85,7 +96,7 @@ func (e SetoptionElem) String() string {
 type SetoptionFlag []SetoptionElem
 
 // Execute applies the setoption element e to o.
-func (o *SetoptionFlag) Execute(opts *Options) error {
+func (o *SetoptionFlag) Execute(opts SetOptionExecutor) error {
 	for _, e := range *o {
 		if err := e.Execute(opts); err != nil {
 			return err
@@ -130,9 +141,18 @@ The file may be newline terminated.`)
 	flag.Var(&s.Options, "O", `Repeated flag used to set AEI options (e.g. -O foo=1 -O bar="xxx"`)
 	flag.BoolVar(&s.UseDatasetWriter, "use_dataset_writer", false, "Enables the Dataset writer for outputting training data")
 	flag.IntVar(&s.DatasetEpoch, "dataset_epoch", 0, "Epoch number to use when writing Dataset files")
+	flag.StringVar(&s.DatasetDir, "dataset_dir", "data/training", "Directory to write Dataset files to. Created if it does not exist.")
+	flag.StringVar(&s.DatasetRunID, "dataset_run_id", "",
+		`Run ID to use in Dataset file names. Defaults to a new time-based ID.
+Pass the ID of an interrupted run to recover its finished games and resume playbatch.`)
 	flag.IntVar(&s.PlayBatchGames, "playbatch_games", 5000, "Number of games to play for `playbatch'")
 	flag.BoolVar(&s.UseSampledMove, "use_suboptimal_move", false, "Sample to best move instead of selecting the best")
 	flag.BoolVar(&s.UseSavedModel, "use_saved_model", false, "Use a saved model configured by model_graph_path*")
 	flag.StringVar(&s.SavedModelPath, "saved_model_path", "", "Path to GraphDef binary protocol buffer")
+	flag.StringVar(&s.ModelServerPath, "model_server", "", "Unix socket of a model_server to evaluate positions with instead of loading a model")
+	flag.StringVar(&s.ResNetWeightsPath, "resnet_weights_path", "", "Path to weights for the pure Go ResNet (see alpha/export_weights.py)")
+	flag.StringVar(&s.TimeControl, "time_control", "", `Time control in arimaa.com notation (e.g. 3s/30s/100/60s/10m).
+Without it, searches run a fixed number of playouts until the controller sends AEI time control
+options, which take precedence. Fields the options do not set default to 1/3/100/5/8.`)
 	return s
 }
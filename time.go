@@ -18,6 +18,9 @@ type TimeInfo struct {
 
 	// Reserve remaining for gold and silver.
 	Reserve [2]time.Duration
+
+	// Turns is the number of turns completed in the game.
+	Turns int
 }
 
 // TimeControl configures game timing control
@@ -37,7 +40,7 @@ type TimeControl struct {
 	MoveReservePercent int
 
 	// MaxTurn is the max turn time.
-	// Set using tcturn [seconds].
+	// Set using tcturntime [seconds].
 	MaxTurn time.Duration
 
 	// MaxReserve reserve time.
@@ -53,7 +56,7 @@ type TimeControl struct {
 	Turns int
 }
 
-// makeTimeControl creates a default blitz game time control equal to "30s/3/100/5/8".
+// makeTimeControl creates a default blitz game time control equal to "1/3/100/5/8".
 // See http://arimaa.com/arimaa/learn/matchRules.html for time control notation.
 func makeTimeControl() TimeControl {
 	return TimeControl{
@@ -65,8 +68,8 @@ func makeTimeControl() TimeControl {
 	}
 }
 
-func (tc TimeControl) newTimeInfo() *TimeInfo {
-	now := time.Now()
+// NewTimeInfo returns the time state of a game starting at now with gold to move.
+func (tc TimeControl) NewTimeInfo(now time.Time) *TimeInfo {
 	return &TimeInfo{
 		GameStart: now,
 		Start:     [2]time.Time{now, now},
@@ -75,9 +78,82 @@ func (tc TimeControl) newTimeInfo() *TimeInfo {
 	}
 }
 
-func (tc TimeControl) resetTurn(t *TimeInfo, c Color) {
+func (tc TimeControl) resetTurn(t *TimeInfo, c Color, now time.Time) {
 	t.Move[c] = tc.Move
-	t.Start[c] = time.Now()
+	t.Start[c] = now
+}
+
+// EndTurn ends the turn of side c at now and starts the turn of the opponent.
+// Following the arimaa.com match rules, MoveReservePercent of the unused move
+// time is added to the reserve of c, while time used beyond the move time is
+// taken from it. The reserve is capped at MaxReserve if set.
+func (tc TimeControl) EndTurn(t *TimeInfo, c Color, now time.Time) {
+	left := t.Move[c] - now.Sub(t.Start[c])
+	if left > 0 {
+		left = left * time.Duration(tc.MoveReservePercent) / 100
+	}
+	t.Reserve[c] += left
+	if t.Reserve[c] < 0 {
+		t.Reserve[c] = 0
+	}
+	if tc.MaxReserve != 0 && t.Reserve[c] > tc.MaxReserve {
+		t.Reserve[c] = tc.MaxReserve
+	}
+	t.Turns++
+	tc.resetTurn(t, c.Opposite(), now)
+}
+
+// set replaces tc with x. Before the first turn ends, the move time and
+// reserves in t are reset as well. t may be nil.
+func (tc *TimeControl) set(t *TimeInfo, x TimeControl) {
+	*tc = x
+	if t != nil && t.Turns == 0 {
+		t.Move = [2]time.Duration{x.Move, x.Move}
+		t.Reserve = [2]time.Duration{x.Reserve, x.Reserve}
+	}
+}
+
+// setOption applies the AEI time control option name with value v to tc.
+// Times are given in seconds. The reserves in t are set by greserve and sreserve.
+// Before the first turn ends, tcmove and tcreserve also reset the move time and
+// reserves in t. Otherwise they take effect on the next turn. t may be nil.
+func (tc *TimeControl) setOption(t *TimeInfo, name string, v int) {
+	d := time.Duration(v) * time.Second
+	switch name {
+	case "tcmove":
+		tc.Move = d
+		if t != nil && t.Turns == 0 {
+			t.Move = [2]time.Duration{d, d}
+		}
+	case "tcreserve":
+		tc.Reserve = d
+		if t != nil && t.Turns == 0 {
+			t.Reserve = [2]time.Duration{d, d}
+		}
+	case "tcpercent":
+		tc.MoveReservePercent = v
+	case "tcmax":
+		tc.MaxReserve = d
+	case "tctotal":
+		tc.GameTotal = d
+	case "tcturns":
+		tc.Turns = v
+	case "tcturntime":
+		tc.MaxTurn = d
+	case "greserve":
+		if t != nil {
+			t.Reserve[Gold] = d
+		}
+	case "sreserve":
+		if t != nil {
+			t.Reserve[Silver] = d
+		}
+	}
+}
+
+// unlimited returns true if tc places no limit on the time of a turn.
+func (tc TimeControl) unlimited() bool {
+	return tc.Move == 0 && tc.Reserve == 0 && tc.MaxTurn == 0 && tc.GameTotal == 0
 }
 
 func (tc TimeControl) gameTimeRemaining(t *TimeInfo, c Color, now time.Time) time.Duration {
@@ -92,15 +168,18 @@ func (tc TimeControl) gameTimeRemaining(t *TimeInfo, c Color, now time.Time) tim
 
 	// Check the total game time.
 	if tc.GameTotal != 0 {
-		if r := tc.GameTotal - used; r < rem {
+		if r := tc.GameTotal - now.Sub(t.GameStart); r < rem {
 			rem = r
 		}
 	}
 
 	// Check the move time remaining plus reserves.
-	turn := t.Move[c] + t.Reserve[c]
-	if r := turn - used; r < rem {
-		rem = r
+	// Both unset means the move time is unlimited.
+	if tc.Move != 0 || tc.Reserve != 0 {
+		turn := t.Move[c] + t.Reserve[c]
+		if r := turn - used; r < rem {
+			rem = r
+		}
 	}
 
 	return rem
@@ -139,11 +218,17 @@ func (tc TimeControl) TurnTimeRemaining(t *TimeInfo, c Color) time.Duration {
 // and turn time with a reasonable fixed maximum move time.
 func (tc TimeControl) FixedOptimalTimeRemaining(t *TimeInfo, c Color) time.Duration {
 	now := time.Now()
+	return tc.fixedOptimalTimeRemaining(t, c, now)
+}
+
+func (tc TimeControl) fixedOptimalTimeRemaining(t *TimeInfo, c Color, now time.Time) time.Duration {
+	game := tc.gameTimeRemaining(t, c, now)
 	turn := tc.turnTimeRemaining(t, c, now)
 	if turn < 0 {
-		return turn
+		// Move time is used up; we're playing on reserve.
+		turn = 0
 	}
-	resv := tc.gameTimeRemaining(t, c, now)
+	resv := game
 	if resv < 30*time.Second {
 		return resv
 	}
@@ -151,9 +236,64 @@ func (tc TimeControl) FixedOptimalTimeRemaining(t *TimeInfo, c Color) time.Durat
 	if resv > 20*time.Minute {
 		return 20 * time.Minute
 	}
+	if turn+resv > game {
+		return game
+	}
 	return turn + resv
 }
 
+// Time management parameters.
+const (
+	// searchTimeMargin is kept in hand on every deadline to cover protocol latency.
+	searchTimeMargin = 250 * time.Millisecond
+
+	// reserveFraction is the inverse of the share of the reserve spent on a typical turn.
+	reserveFraction = 20
+)
+
+// searchBudget is the time allotted to a single search.
+type searchBudget struct {
+	start   time.Time     // start of the search
+	base    time.Duration // initial soft limit
+	optimal time.Duration // soft limit; extended when the best move is unstable
+	maximum time.Duration // hard limit; never exceeded
+}
+
+// newSearchBudget allots time for a search by side c starting at now.
+// The soft limit spends the move time plus a share of the reserve,
+// while the hard limit is given by FixedOptimalTimeRemaining.
+// ok is false if the time control does not limit the turn.
+func (tc TimeControl) newSearchBudget(t *TimeInfo, c Color, now time.Time) (b searchBudget, ok bool) {
+	if tc.unlimited() {
+		return searchBudget{}, false
+	}
+	maximum := tc.fixedOptimalTimeRemaining(t, c, now) - searchTimeMargin
+	if maximum < 0 {
+		maximum = 0
+	}
+	optimal := t.Move[c] - now.Sub(t.Start[c])
+	if optimal < 0 {
+		optimal = 0
+	}
+	optimal += t.Reserve[c] / reserveFraction
+	if optimal > maximum {
+		optimal = maximum
+	}
+	return searchBudget{
+		start:   now,
+		base:    optimal,
+		optimal: optimal,
+		maximum: maximum,
+	}, true
+}
+
+// extend extends the soft limit by half the base time up to the hard limit.
+func (b *searchBudget) extend() {
+	if b.optimal += b.base / 2; b.optimal > b.maximum {
+		b.optimal = b.maximum
+	}
+}
+
 func computebfNd(d int, b float64) float64 {
 	n := b
 	for i := 2; i <= d; i++ {
@@ -0,0 +1,189 @@
+package zoo
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const day = 24 * time.Hour
+
+var timeUnitPattern = regexp.MustCompile(`^(?:(\d+)d)?(?:(\d+)h)?(?:(\d+)m)?(?:(\d+(?:\.\d+)?)s)?$`)
+
+// parseTimeField parses a time field of the time control notation.
+// Fields are either given with units (e.g. 1d, 2h, 1m30s, 0.5s) or as
+// colon separated numbers starting with unit (e.g. 3 or 3:30 with
+// unit minutes is 3 minutes or 3 minutes and 30 seconds).
+func parseTimeField(s string, unit time.Duration) (time.Duration, error) {
+	if s == "" {
+		return 0, fmt.Errorf("empty time")
+	}
+	if strings.ContainsAny(s, "dhms") {
+		matches := timeUnitPattern.FindStringSubmatch(s)
+		if matches == nil {
+			return 0, fmt.Errorf("time %q does not match /%s/", s, timeUnitPattern)
+		}
+		var d time.Duration
+		for i, u := range []time.Duration{day, time.Hour, time.Minute} {
+			if matches[i+1] == "" {
+				continue
+			}
+			v, err := strconv.Atoi(matches[i+1])
+			if err != nil {
+				return 0, err
+			}
+			d += time.Duration(v) * u
+		}
+		if matches[4] != "" {
+			// Seconds may have a fraction.
+			v, err := time.ParseDuration(matches[4] + "s")
+			if err != nil {
+				return 0, err
+			}
+			d += v
+		}
+		return d, nil
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) > 2 {
+		return 0, fmt.Errorf("time %q has too many fields", s)
+	}
+	var d time.Duration
+	for i, part := range parts {
+		v, err := strconv.Atoi(part)
+		if err != nil {
+			return 0, fmt.Errorf("bad time %q: %v", s, err)
+		}
+		if i > 0 {
+			// The second field is in the next smaller unit.
+			if unit == time.Hour {
+				unit = time.Minute
+			} else {
+				unit = time.Second
+			}
+		}
+		d += time.Duration(v) * unit
+	}
+	return d, nil
+}
+
+// appendTimeField appends the time field d to sb using units.
+// Seconds are written with a fraction if d is not a whole number of seconds.
+func appendTimeField(sb *strings.Builder, d time.Duration) {
+	if d == 0 {
+		sb.WriteByte('0')
+		return
+	}
+	for _, u := range []struct {
+		d time.Duration
+		c byte
+	}{{day, 'd'}, {time.Hour, 'h'}, {time.Minute, 'm'}} {
+		if n := d / u.d; n > 0 {
+			fmt.Fprintf(sb, "%d%c", n, u.c)
+			d -= n * u.d
+		}
+	}
+	if d == 0 {
+		return
+	}
+	fmt.Fprintf(sb, "%d", d/time.Second)
+	if frac := d % time.Second; frac != 0 {
+		fmt.Fprintf(sb, ".%s", strings.TrimRight(fmt.Sprintf("%09d", frac), "0"))
+	}
+	sb.WriteByte('s')
+}
+
+// ParseTimeControl parses the time control in the arimaa.com notation M/R/P/L/G/T:
+//
+//	M  move time per turn (minutes:seconds),
+//	R  initial reserve (minutes:seconds),
+//	P  percent of unused move time added to reserve (default 100),
+//	L  reserve limit (minutes:seconds; default 0),
+//	G  game total (hours:minutes) or turn limit followed by t (default 0),
+//	T  max turn time (minutes:seconds; default 0).
+//
+// Times may be given with units instead (e.g. 3s/30s/100/60s/10m). Seconds given with
+// units may have a fraction (e.g. 0.5s).
+// 0 means unlimited. See http://arimaa.com/arimaa/learn/matchRules.html.
+func ParseTimeControl(s string) (TimeControl, error) {
+	fields := strings.Split(strings.TrimSpace(s), "/")
+	if len(fields) < 2 || len(fields) > 6 {
+		return TimeControl{}, fmt.Errorf("time control %q: want 2 to 6 fields separated by /", s)
+	}
+	tc := TimeControl{MoveReservePercent: 100}
+	var err error
+	if tc.Move, err = parseTimeField(fields[0], time.Minute); err != nil {
+		return TimeControl{}, fmt.Errorf("time control %q: move: %v", s, err)
+	}
+	if tc.Reserve, err = parseTimeField(fields[1], time.Minute); err != nil {
+		return TimeControl{}, fmt.Errorf("time control %q: reserve: %v", s, err)
+	}
+	if len(fields) > 2 {
+		if tc.MoveReservePercent, err = strconv.Atoi(fields[2]); err != nil || tc.MoveReservePercent < 0 {
+			return TimeControl{}, fmt.Errorf("time control %q: bad percent: %q", s, fields[2])
+		}
+	}
+	if len(fields) > 3 {
+		if tc.MaxReserve, err = parseTimeField(fields[3], time.Minute); err != nil {
+			return TimeControl{}, fmt.Errorf("time control %q: reserve limit: %v", s, err)
+		}
+	}
+	if len(fields) > 4 {
+		if g := fields[4]; strings.HasSuffix(g, "t") {
+			if tc.Turns, err = strconv.Atoi(strings.TrimSuffix(g, "t")); err != nil || tc.Turns < 0 {
+				return TimeControl{}, fmt.Errorf("time control %q: bad turn limit: %q", s, g)
+			}
+		} else if tc.GameTotal, err = parseTimeField(g, time.Hour); err != nil {
+			return TimeControl{}, fmt.Errorf("time control %q: game total: %v", s, err)
+		}
+	}
+	if len(fields) > 5 {
+		if tc.MaxTurn, err = parseTimeField(fields[5], time.Minute); err != nil {
+			return TimeControl{}, fmt.Errorf("time control %q: turn limit: %v", s, err)
+		}
+	}
+	return tc, nil
+}
+
+// String returns the time control in the arimaa.com notation using units.
+// Trailing default fields are omitted. The game total takes precedence over
+// the turn limit when both are set since the notation only has room for one.
+func (tc TimeControl) String() string {
+	var sb strings.Builder
+	appendTimeField(&sb, tc.Move)
+	sb.WriteByte('/')
+	appendTimeField(&sb, tc.Reserve)
+	n := 2
+	switch {
+	case tc.MaxTurn != 0:
+		n = 6
+	case tc.GameTotal != 0 || tc.Turns != 0:
+		n = 5
+	case tc.MaxReserve != 0:
+		n = 4
+	case tc.MoveReservePercent != 100:
+		n = 3
+	}
+	if n > 2 {
+		fmt.Fprintf(&sb, "/%d", tc.MoveReservePercent)
+	}
+	if n > 3 {
+		sb.WriteByte('/')
+		appendTimeField(&sb, tc.MaxReserve)
+	}
+	if n > 4 {
+		sb.WriteByte('/')
+		if tc.GameTotal == 0 && tc.Turns != 0 {
+			fmt.Fprintf(&sb, "%dt", tc.Turns)
+		} else {
+			appendTimeField(&sb, tc.GameTotal)
+		}
+	}
+	if n > 5 {
+		sb.WriteByte('/')
+		appendTimeField(&sb, tc.MaxTurn)
+	}
+	return sb.String()
+}
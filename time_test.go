@@ -0,0 +1,486 @@
+package zoo
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock is a deterministic clock advancing by step on each call.
+type fakeClock struct {
+	t    time.Time
+	step time.Duration
+}
+
+func newFakeClock(step time.Duration) *fakeClock {
+	return &fakeClock{t: time.Unix(1600000000, 0), step: step}
+}
+
+func (c *fakeClock) Now() time.Time {
+	t := c.t
+	c.t = c.t.Add(c.step)
+	return t
+}
+
+func TestNewSearchBudget(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	for _, tc := range []struct {
+		name        string
+		tc          TimeControl
+		used        time.Duration
+		wantOptimal time.Duration
+		wantMaximum time.Duration
+		wantOK      bool
+	}{{
+		name: "unlimited",
+	}, {
+		name: "move and reserve",
+		tc: TimeControl{
+			Move:    30 * time.Second,
+			Reserve: 3 * time.Minute,
+		},
+		wantOptimal: 39 * time.Second,
+		wantMaximum: 100*time.Second - searchTimeMargin,
+		wantOK:      true,
+	}, {
+		name: "move only",
+		tc: TimeControl{
+			Move: 3 * time.Second,
+		},
+		wantOptimal: 3*time.Second - searchTimeMargin,
+		wantMaximum: 3*time.Second - searchTimeMargin,
+		wantOK:      true,
+	}, {
+		name: "playing on reserve",
+		tc: TimeControl{
+			Move:    30 * time.Second,
+			Reserve: 60 * time.Second,
+		},
+		used:        45 * time.Second,
+		wantOptimal: 3 * time.Second,
+		wantMaximum: 15*time.Second - searchTimeMargin,
+		wantOK:      true,
+	}, {
+		name: "turn limit",
+		tc: TimeControl{
+			Move:    30 * time.Second,
+			Reserve: 3 * time.Minute,
+			MaxTurn: 10 * time.Second,
+		},
+		wantOptimal: 10*time.Second - searchTimeMargin,
+		wantMaximum: 10*time.Second - searchTimeMargin,
+		wantOK:      true,
+	}, {
+		name: "out of time",
+		tc: TimeControl{
+			Move: 3 * time.Second,
+		},
+		used:   5 * time.Second,
+		wantOK: true,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			info := tc.tc.NewTimeInfo(now.Add(-tc.used))
+			b, ok := tc.tc.newSearchBudget(info, Gold, now)
+			if ok != tc.wantOK {
+				t.Fatalf("newSearchBudget(): got ok=%v, want ok=%v", ok, tc.wantOK)
+			}
+			if b.optimal != tc.wantOptimal {
+				t.Errorf("newSearchBudget(): got optimal=%v, want optimal=%v", b.optimal, tc.wantOptimal)
+			}
+			if b.maximum != tc.wantMaximum {
+				t.Errorf("newSearchBudget(): got maximum=%v, want maximum=%v", b.maximum, tc.wantMaximum)
+			}
+		})
+	}
+}
+
+// newTestLimits creates timed search limits for a tree whose root has one
+// complete single step move for each of the given runs.
+func newTestLimits(now time.Time, elapsed time.Duration, runs ...uint32) (*searchLimits, *Tree) {
+	t := NewEmptyTree(nil)
+	t.root = &TreeNode{t: t, first: true}
+	for _, r := range runs {
+		t.root.children = append(t.root.children, &TreeNode{t: t, parent: t.root, runs: r})
+	}
+	l := &searchLimits{
+		timed: true,
+		budget: searchBudget{
+			start:   now.Add(-elapsed),
+			base:    10 * time.Second,
+			optimal: 10 * time.Second,
+			maximum: 20 * time.Second,
+		},
+		now:  func() time.Time { return now },
+		done: searchCheckInterval - 1,
+	}
+	return l, t
+}
+
+func TestSearchLimitsUpdate(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	for _, tc := range []struct {
+		name        string
+		elapsed     time.Duration
+		runs        []uint32
+		best        int // index of the best child at the last check or -1
+		wantStop    bool
+		wantOptimal time.Duration
+	}{{
+		name:        "runner up can catch up",
+		elapsed:     5 * time.Second,
+		runs:        []uint32{60, 40},
+		best:        -1,
+		wantOptimal: 10 * time.Second,
+	}, {
+		name:        "runner up cannot catch up",
+		elapsed:     5 * time.Second,
+		runs:        []uint32{900, 100},
+		best:        -1,
+		wantStop:    true,
+		wantOptimal: 10 * time.Second,
+	}, {
+		name:        "only move",
+		elapsed:     time.Second,
+		runs:        []uint32{100},
+		best:        -1,
+		wantStop:    true,
+		wantOptimal: 10 * time.Second,
+	}, {
+		name:        "soft deadline",
+		elapsed:     10 * time.Second,
+		runs:        []uint32{60, 40},
+		best:        0,
+		wantStop:    true,
+		wantOptimal: 10 * time.Second,
+	}, {
+		name:        "unstable best move extends",
+		elapsed:     10 * time.Second,
+		runs:        []uint32{60, 40},
+		best:        1,
+		wantOptimal: 15 * time.Second,
+	}, {
+		name:        "hard deadline",
+		elapsed:     20 * time.Second,
+		runs:        []uint32{60, 40},
+		best:        1,
+		wantStop:    true,
+		wantOptimal: 10 * time.Second,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			l, tree := newTestLimits(now, tc.elapsed, tc.runs...)
+			if tc.best >= 0 {
+				l.best = tree.root.children[tc.best]
+			}
+			l.update(tree)
+			if gotStop := l.stop != 0; gotStop != tc.wantStop {
+				t.Errorf("update(): got stop=%v, want stop=%v", gotStop, tc.wantStop)
+			}
+			if l.budget.optimal != tc.wantOptimal {
+				t.Errorf("update(): got optimal=%v, want optimal=%v", l.budget.optimal, tc.wantOptimal)
+			}
+		})
+	}
+}
+
+func TestTimedSearch(t *testing.T) {
+	engine, err := NewEngine(&EngineSettings{Seed: 1337}, &AEISettings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := newFakeClock(50 * time.Millisecond)
+	engine.now = clock.Now
+	engine.timeControl = TimeControl{Move: 2 * time.Second}
+	engine.NewGame()
+	p, err := ParseShortPosition("g [rrrrrrrrhdcemcdh                                HDCMECDHRRRRRRRR]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.Pos = p
+
+	start := clock.t
+	engine.GoWait()
+	if used := clock.t.Sub(start); used > engine.timeControl.Move {
+		t.Errorf("GoWait(): used %v of %v move time", used, engine.timeControl.Move)
+	}
+	if engine.bestMove == nil {
+		t.Errorf("GoWait(): got no best move")
+	}
+}
+
+func TestEndTurn(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	for _, tc := range []struct {
+		name        string
+		tc          TimeControl
+		used        time.Duration
+		wantReserve time.Duration
+	}{{
+		name: "unused move time",
+		tc: TimeControl{
+			Move:               30 * time.Second,
+			Reserve:            time.Minute,
+			MoveReservePercent: 100,
+		},
+		used:        10 * time.Second,
+		wantReserve: 80 * time.Second,
+	}, {
+		name: "partial unused move time",
+		tc: TimeControl{
+			Move:               30 * time.Second,
+			Reserve:            time.Minute,
+			MoveReservePercent: 50,
+		},
+		used:        10 * time.Second,
+		wantReserve: 70 * time.Second,
+	}, {
+		name: "used reserve",
+		tc: TimeControl{
+			Move:               30 * time.Second,
+			Reserve:            time.Minute,
+			MoveReservePercent: 100,
+		},
+		used:        45 * time.Second,
+		wantReserve: 45 * time.Second,
+	}, {
+		name: "max reserve",
+		tc: TimeControl{
+			Move:               30 * time.Second,
+			Reserve:            time.Minute,
+			MoveReservePercent: 100,
+			MaxReserve:         70 * time.Second,
+		},
+		used:        10 * time.Second,
+		wantReserve: 70 * time.Second,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			info := tc.tc.NewTimeInfo(now.Add(-tc.used))
+			tc.tc.EndTurn(info, Gold, now)
+			if got := info.Reserve[Gold]; got != tc.wantReserve {
+				t.Errorf("EndTurn(): got reserve=%v, want reserve=%v", got, tc.wantReserve)
+			}
+			if got := info.Start[Silver]; !got.Equal(now) {
+				t.Errorf("EndTurn(): got silver start=%v, want start=%v", got, now)
+			}
+		})
+	}
+}
+
+func TestTimeOptions(t *testing.T) {
+	engine, err := NewEngine(&EngineSettings{Seed: 1337}, &AEISettings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := newFakeClock(0)
+	engine.now = clock.Now
+	for _, cmd := range []string{
+		"newgame",
+		"setoption name tcmove value 30",
+		"setoption name tcreserve value 60",
+		"setoption name tcpercent value 50",
+		"setoption name tcmax value 90",
+		"setoption name tctotal value 3600",
+		"setoption name tcturns value 100",
+		"setoption name tcturntime value 120",
+	} {
+		if err := engine.ExecuteCommand(cmd); err != nil {
+			t.Fatalf("ExecuteCommand(%q): %v", cmd, err)
+		}
+	}
+	wantTC := TimeControl{
+		Move:               30 * time.Second,
+		Reserve:            time.Minute,
+		MoveReservePercent: 50,
+		MaxReserve:         90 * time.Second,
+		GameTotal:          time.Hour,
+		Turns:              100,
+		MaxTurn:            2 * time.Minute,
+	}
+	if engine.timeControl != wantTC {
+		t.Errorf("time control: got %+v, want %+v", engine.timeControl, wantTC)
+	}
+	if got, want := engine.timeInfo.Reserve, [2]time.Duration{time.Minute, time.Minute}; got != want {
+		t.Errorf("reserves after tcreserve: got %v, want %v", got, want)
+	}
+
+	// Gold spends 10s on setup and earns half of the remaining 20s.
+	clock.t = clock.t.Add(10 * time.Second)
+	if err := engine.ExecuteCommand("makemove Ra1 Rb1 Rc1 Rd1 Re1 Rf1 Rg1 Rh1 Da2 Hb2 Cc2 Cd2 Ee2 Mf2 Hg2 Dh2"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := engine.timeInfo.Reserve[Gold], 70*time.Second; got != want {
+		t.Errorf("gold reserve after makemove: got %v, want %v", got, want)
+	}
+	if got := engine.timeInfo.Start[Silver]; !got.Equal(clock.t) {
+		t.Errorf("silver turn start after makemove: got %v, want %v", got, clock.t)
+	}
+
+	// Reserves sent by the controller override our own accounting.
+	for _, cmd := range []string{
+		"setoption name greserve value 65",
+		"setoption name sreserve value 55",
+	} {
+		if err := engine.ExecuteCommand(cmd); err != nil {
+			t.Fatalf("ExecuteCommand(%q): %v", cmd, err)
+		}
+	}
+	if got, want := engine.timeInfo.Reserve, [2]time.Duration{65 * time.Second, 55 * time.Second}; got != want {
+		t.Errorf("reserves after greserve and sreserve: got %v, want %v", got, want)
+	}
+}
+
+func TestNewGameUntimed(t *testing.T) {
+	engine, err := NewEngine(&EngineSettings{Seed: 1337}, &AEISettings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.ExecuteCommand("newgame"); err != nil {
+		t.Fatal(err)
+	}
+	// Without a time control the search runs the default playouts.
+	if l := engine.newSearchLimits(engine.Pos, searchNormal); l.timed || l.playouts != searchPlayouts {
+		t.Errorf("newSearchLimits(): got timed %v with %d playouts, want %d untimed playouts", l.timed, l.playouts, searchPlayouts)
+	}
+	// A time control option starts the clock, which the next game keeps.
+	for _, cmd := range []string{"setoption name tcmove value 30", "newgame"} {
+		if err := engine.ExecuteCommand(cmd); err != nil {
+			t.Fatal(err)
+		}
+		if l := engine.newSearchLimits(engine.Pos, searchNormal); !l.timed {
+			t.Errorf("newSearchLimits() after %q: got untimed search, want timed", cmd)
+		}
+	}
+}
+
+func TestParseTimeControl(t *testing.T) {
+	for _, tc := range []struct {
+		input      string
+		want       TimeControl
+		wantString string
+		wantErr    bool
+	}{{
+		input: "3s/30s/100/60s/10m",
+		want: TimeControl{
+			Move:               3 * time.Second,
+			Reserve:            30 * time.Second,
+			MoveReservePercent: 100,
+			MaxReserve:         time.Minute,
+			GameTotal:          10 * time.Minute,
+		},
+		wantString: "3s/30s/100/1m/10m",
+	}, {
+		input:      "1/3/100/5/8",
+		want:       makeTimeControl(),
+		wantString: "1m/3m/100/5m/8h",
+	}, {
+		input: "0:30/4",
+		want: TimeControl{
+			Move:               30 * time.Second,
+			Reserve:            4 * time.Minute,
+			MoveReservePercent: 100,
+		},
+		wantString: "30s/4m",
+	}, {
+		input: "2m/2m/75",
+		want: TimeControl{
+			Move:               2 * time.Minute,
+			Reserve:            2 * time.Minute,
+			MoveReservePercent: 75,
+		},
+		wantString: "2m/2m/75",
+	}, {
+		input: "1m30s/1d/100/0/90t/5m",
+		want: TimeControl{
+			Move:               90 * time.Second,
+			Reserve:            24 * time.Hour,
+			MoveReservePercent: 100,
+			Turns:              90,
+			MaxTurn:            5 * time.Minute,
+		},
+		wantString: "1m30s/1d/100/0/90t/5m",
+	}, {
+		input: "15s/1/100/3/2:30",
+		want: TimeControl{
+			Move:               15 * time.Second,
+			Reserve:            time.Minute,
+			MoveReservePercent: 100,
+			MaxReserve:         3 * time.Minute,
+			GameTotal:          150 * time.Minute,
+		},
+		wantString: "15s/1m/100/3m/2h30m",
+	}, {
+		input: "0.5s/1m0.25s/100/0/0/1.000000001s",
+		want: TimeControl{
+			Move:               500 * time.Millisecond,
+			Reserve:            time.Minute + 250*time.Millisecond,
+			MoveReservePercent: 100,
+			MaxTurn:            time.Second + time.Nanosecond,
+		},
+		wantString: "0.5s/1m0.25s/100/0/0/1.000000001s",
+	}, {
+		input:   "0.5/1",
+		wantErr: true,
+	}, {
+		input:   "30s",
+		wantErr: true,
+	}, {
+		input:   "30s/1/100/3/8/1/1",
+		wantErr: true,
+	}, {
+		input:   "30x/1",
+		wantErr: true,
+	}, {
+		input:   "30s/1/all",
+		wantErr: true,
+	}, {
+		input:   "30s/1/100/3/xt",
+		wantErr: true,
+	}, {
+		input:   "30s/1:2:3",
+		wantErr: true,
+	}} {
+		t.Run(tc.input, func(t *testing.T) {
+			got, err := ParseTimeControl(tc.input)
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("ParseTimeControl(%q): got err = %v, want err = %v", tc.input, err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if got != tc.want {
+				t.Errorf("ParseTimeControl(%q): got %+v, want %+v", tc.input, got, tc.want)
+			}
+			if s := got.String(); s != tc.wantString {
+				t.Errorf("String(): got %q, want %q", s, tc.wantString)
+			}
+			if rt, err := ParseTimeControl(got.String()); err != nil || rt != got {
+				t.Errorf("ParseTimeControl(%q): round trip got %+v, %v, want %+v", got.String(), rt, err, got)
+			}
+		})
+	}
+}
+
+func TestTimeControlOption(t *testing.T) {
+	engine, err := NewEngine(&EngineSettings{Seed: 1337, TimeControl: "2m/2m"}, &AEISettings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := engine.timeControl.String(), "2m/2m"; got != want {
+		t.Errorf("time_control flag: got %q, want %q", got, want)
+	}
+	for _, cmd := range []string{
+		"newgame",
+		"setoption name tc value 3s/30s/100/60s/10m",
+	} {
+		if err := engine.ExecuteCommand(cmd); err != nil {
+			t.Fatalf("ExecuteCommand(%q): %v", cmd, err)
+		}
+	}
+	if got, want := engine.timeControl.String(), "3s/30s/100/1m/10m"; got != want {
+		t.Errorf("setoption tc: got %q, want %q", got, want)
+	}
+	if got, want := engine.timeInfo.Reserve, [2]time.Duration{30 * time.Second, 30 * time.Second}; got != want {
+		t.Errorf("reserves after setoption tc: got %v, want %v", got, want)
+	}
+	if err := engine.ExecuteCommand("setoption name tc value 3s"); err == nil {
+		t.Errorf("setoption tc with bad time control: got err = nil, want err")
+	}
+}
@@ -5,20 +5,39 @@ import (
 	"math"
 	"math/rand"
 	"sort"
+	"sync"
+	"sync/atomic"
+	"unsafe"
 )
 
 // Tree represents a game tree for MCTS in memory.
+// Select, Expand and Backprop are safe to call from multiple goroutines.
 type Tree struct {
 	root   *TreeNode           // root node
 	tt     *TranspositionTable // tt for looking up transpositions
 	p      *Pos                // root position
 	sample bool                // sample mode
+	cpuct  float64             // exploration constant for PUCT selection
+	fpu    float64             // first play urgency reduction for unvisited children
+	noised *TreeNode           // root with Dirichlet noise mixed into its priors
+	m      sync.Mutex          // guards tt
+
+	temperature      float64 // initial temperature for sampling the best move
+	temperatureMoves int     // move number at which the temperature has decayed to 0
 }
 
+// Default PUCT selection parameters.
+const (
+	defaultCPuct = 1.25
+	defaultFPU   = 0.2
+)
+
 // NewEmptyTree creates a new tree with no root position.
 func NewEmptyTree(tt *TranspositionTable) *Tree {
 	t := &Tree{
-		tt: tt,
+		tt:    tt,
+		cpuct: defaultCPuct,
+		fpu:   defaultFPU,
 	}
 	return t
 }
@@ -33,28 +52,81 @@ func (t *Tree) SetSample(sample bool) {
 	t.sample = sample
 }
 
+// SetTemperature sets the temperature schedule for sampling the best move in sample mode.
+// The temperature decays linearly from temperature at move 1 to 0 at move number moves.
+// From then on the most visited move is played.
+func (t *Tree) SetTemperature(temperature float64, moves int) {
+	t.temperature = temperature
+	t.temperatureMoves = moves
+}
+
+// moveTemperature returns the temperature for the root position.
+func (t *Tree) moveTemperature() float64 {
+	if !t.sample || t.temperatureMoves <= 0 {
+		return 0
+	}
+	return t.temperature * (1 - float64(t.p.MoveNum()-1)/float64(t.temperatureMoves))
+}
+
+// AddRootNoise mixes Dirichlet noise with parameter alpha into the priors of the root children:
+//
+//	P = (1 - fraction) * P + fraction * noise
+//
+// Noise is only added once for each root.
+func (t *Tree) AddRootNoise(r *rand.Rand, alpha, fraction float64) {
+	n := t.root
+	if n == nil || fraction <= 0 || t.noised == n || len(n.children) == 0 {
+		return
+	}
+	t.noised = n
+	noise := make([]float64, len(n.children))
+	sampleDirichlet(r, alpha, noise)
+	for i, c := range n.children {
+		c.prior = float32((1-fraction)*float64(c.prior) + fraction*noise[i])
+	}
+}
+
+// SetPUCT sets the exploration constant and first play urgency reduction used by Select.
+// Unvisited children are valued at the mean value of their parent reduced by fpu.
+func (t *Tree) SetPUCT(cpuct, fpu float64) {
+	t.cpuct = cpuct
+	t.fpu = fpu
+}
+
 // UpdateRoot updates the root position to p if p differs from the stored root position.
+// Otherwise the tree is kept to continue searching from the stored root.
 func (t *Tree) UpdateRoot(p *Pos, model ModelInterface) {
 	if t.p == nil || t.p.Hash() != p.Hash() {
 		t.p = p
 		t.root = t.NewTreeNode(nil, 0, false, 1, true)
 		t.root.rootify(p, model)
+		return
 	}
+	t.p = p
 }
 
 // Select the next node to expand at position p.
+// A virtual loss is applied to each node along the selected path so that
+// concurrent selections are steered toward different lines. The virtual
+// loss is removed when the node is expanded and its value is backpropagated.
 func (t *Tree) Select(p *Pos) (*TreeNode, *Pos) {
 	p = p.Clone()
 	n := t.root
 	for {
-		if len(n.children) == 0 {
+		n.m.Lock()
+		if len(n.children) == 0 || n.Proof() != 0 {
+			// Leaf or solved subtree.
+			n.m.Unlock()
 			return n, p
 		}
 		for _, c := range n.children {
-			c.computePriority(0)
+			c.computePriority()
 		}
 		sort.Stable(byPriority(n.children))
-		n = n.children[0]
+		c := n.children[0]
+		atomic.AddInt32(&c.virtualLoss, 1)
+		n.m.Unlock()
+		n = c
 		s, pass := n.Step()
 		if pass {
 			p.Pass()
@@ -70,41 +142,152 @@ func (t *Tree) Reset() {
 	t.p = nil
 }
 
-// RetainOptimalSubtree removes all suboptimal subtrees and resets
-// the frontier. After calling this method, the tree is ready to evaluate the next turn.
+// RetainOptimalSubtree makes n the root at position p and removes all other subtrees.
+// Runs, values and policies under n are kept. After calling this method, the tree is
+// ready to evaluate the next turn.
 func (t *Tree) RetainOptimalSubtree(p *Pos, n *TreeNode, model ModelInterface) {
-	// Reset the tree root;
-	// Clear the step and "rootify".
+	t.p = p
 	t.root = n
 	n.rootify(p, model)
 }
 
+// Advance retains the subtree under the move m played from the root position.
+// p is the position after m. Advance reports whether the position was found in the tree.
+// Otherwise the tree is reset and the next search starts from scratch.
+func (t *Tree) Advance(m Move, p *Pos) bool {
+	n := t.root
+	for _, s := range m {
+		if n == nil {
+			break
+		}
+		if s.Capture() {
+			continue
+		}
+		n = n.child(s, false)
+	}
+	if n != nil && n.first {
+		// The move ended early with a pass.
+		n = n.child(0, true)
+	}
+	if n == nil || !n.expanded {
+		t.Reset()
+		return false
+	}
+	// n is expanded so no model evaluation is needed.
+	t.RetainOptimalSubtree(p.Clone(), n, nil)
+	return true
+}
+
 // BestMove returns the best move from the tree after all runs have been performed.
 // This is equivalent to the path from root with the greatest number of playouts.
+// In sample mode each step is instead sampled by runs with the temperature for the
+// current move number (see SetTemperature). If the path leaves the tree before the
+// turn is complete, the remaining steps are expanded with model and chosen by prior.
+// Proven wins are always chosen and a goal found by Pos.Goal completes the move.
+// If model is nil the move may be incomplete.
 // If the best move would not be legal (this is possible given a terminal root node)
 // nil and false are returned instead.
-func (t *Tree) BestMove(r *rand.Rand) (m Move, v Value, n *TreeNode, ok bool) {
+func (t *Tree) BestMove(r *rand.Rand, model ModelInterface) (m Move, v Value, n *TreeNode, ok bool) {
 	n = t.root
-	for n.first && len(n.children) > 0 {
+	p := t.p.Clone()
+	temperature := t.moveTemperature()
+	var first *TreeNode
+	for n.first {
+		if !n.expanded && model != nil {
+			// Backprop removes a virtual loss along the path.
+			for a := n; a.parent != nil; a = a.parent {
+				atomic.AddInt32(&a.virtualLoss, 1)
+			}
+			n.Expand(p, model)
+		}
+		if n.Proof() == Win && len(n.children) == 0 && !p.Terminal().Terminal() {
+			// Proven by the goal search so the turn ends with the goal.
+			if goal, ok := p.Goal(); ok {
+				m = append(m, goal...)
+				if first == nil {
+					first = n
+				}
+			}
+			break
+		}
+		if len(n.children) == 0 {
+			break
+		}
 		sort.Stable(byRuns(n.children))
+		if n.children[0].Runs() == 0 {
+			sort.Stable(byPrior(n.children))
+		}
+		// Steps of the first turn are chosen by the root side.
+		sort.Stable(byProof(n.children))
+		if temperature > 0 && n.children[0].Proof() == 0 {
+			runs := make([]uint32, len(n.children))
+			for i, c := range n.children {
+				if c.Proof() == 0 {
+					runs[i] = c.Runs()
+				}
+			}
+			i := sampleTemperature(r, runs, temperature)
+			n.children[0], n.children[i] = n.children[i], n.children[0]
+		}
 		n = n.children[0]
+		if first == nil {
+			first = n
+		}
 		step, pass := n.Step()
 		if pass {
-			t.p.Pass()
 			break
 		}
-		cap := t.p.Step(step)
+		cap := p.Step(step)
 		m = append(m, step)
 		if cap.Capture() {
 			m = append(m, cap)
 		}
 	}
 	if len(m) > 0 {
-		return m, Value(float64(t.root.children[0].Weight()) / float64(t.root.children[0].Runs())), n, true
+		if v = first.Proof(); v == 0 {
+			if runs := first.Runs(); runs > 0 {
+				v = first.Weight() / Value(runs)
+			}
+		}
+		return m, v, n, true
 	}
 	return nil, 0, n, false
 }
 
+// bestMoveGap follows the most visited children through the first turn and returns
+// the last node of the best move. gap is the smallest difference in runs between
+// the most and second most visited children along the way. Forced steps do not
+// contribute to gap. ok is false if the best move is not yet complete in the tree.
+func (t *Tree) bestMoveGap() (n *TreeNode, gap uint32, ok bool) {
+	gap = math.MaxUint32
+	n = t.root
+	for n.first {
+		n.m.Lock()
+		var (
+			best                 *TreeNode
+			bestRuns, secondRuns uint32
+			forced               = len(n.children) < 2
+		)
+		for _, c := range n.children {
+			switch runs := c.Runs(); {
+			case best == nil || runs > bestRuns:
+				best, bestRuns, secondRuns = c, runs, bestRuns
+			case runs > secondRuns:
+				secondRuns = runs
+			}
+		}
+		n.m.Unlock()
+		if best == nil {
+			return n, gap, false
+		}
+		if !forced && bestRuns-secondRuns < gap {
+			gap = bestRuns - secondRuns
+		}
+		n = best
+	}
+	return n, gap, true
+}
+
 // RootChildren returns a shallow copy of the children at this root position.
 func (t *Tree) RootChildren() []*TreeNode {
 	if t.root == nil {
@@ -132,17 +315,23 @@ func (t *Tree) Debug(l *log.Logger) {
 
 // TreeNode represents a game tree node for MCTS in memory.
 type TreeNode struct {
-	t        *Tree       // parent tree containing the frontier heap
-	side     Value       // side-to-move multipier; can be 1 or -1.
-	weight   Value       // cumulative value of this state; divide by Runs to normalize.
-	runs     uint32      // number of runs through this node.
-	policy   []float32   // policy from the model, if run.
-	priority float64     // computed priority ordering for this node based on value, policy, and runs.
-	step     Step        // step played to arrive at this position.
-	pass     bool        // pass was played to arrive at this position.
-	parent   *TreeNode   // parent node.
-	first    bool        // first turn; candidate for bestmove.
-	children []*TreeNode // expanded children of this node; used on first turn only to recover bestmove.
+	t           *Tree       // parent tree containing the frontier heap
+	side        Value       // side-to-move multipier; can be 1 or -1.
+	weight      Value       // cumulative value of this state; divide by Runs to normalize; atomic.
+	runs        uint32      // number of runs through this node; atomic.
+	virtualLoss int32       // number of in-flight selections through this node; atomic.
+	value       Value       // value backpropagated when this node was expanded.
+	proof       int32       // proven value from the root perspective: 1 (win), -1 (loss) or 0 (unknown); atomic.
+	expanded    bool        // Expand has been called on this node.
+	policy      []float32   // policy from the model, if run.
+	prior       float32     // probability of selecting this node from the parent's policy.
+	priority    float64     // computed priority ordering for this node based on value, policy, and runs.
+	step        Step        // step played to arrive at this position.
+	pass        bool        // pass was played to arrive at this position.
+	parent      *TreeNode   // parent node.
+	first       bool        // first turn; candidate for bestmove.
+	children    []*TreeNode // expanded children of this node; used on first turn only to recover bestmove.
+	m           sync.Mutex  // guards expanded, value, policy and children.
 }
 
 // NewTreeNode creates a new game tree node for p with initial stats populated from the tt.
@@ -172,14 +361,9 @@ func (n *TreeNode) StepIndex() uint8 {
 	return n.step.Index()
 }
 
-// HasParent returns true if n has a non-nil parent.
-func (n *TreeNode) HasParent() bool {
-	return n.parent != nil && n.parent.Runs() > 0
-}
-
 // Runs returns the number of MCTS runs propagated through this node.
 func (n *TreeNode) Runs() uint32 {
-	return n.runs
+	return atomic.LoadUint32(&n.runs)
 }
 
 // ParentRuns returns the number of MCTS runs propagated through n's parent.
@@ -193,7 +377,62 @@ func (n *TreeNode) ParentRuns() uint32 {
 // Weight returns the total value of node n.
 // Divide by Runs to normalize.
 func (n *TreeNode) Weight() Value {
-	return n.weight
+	return Value(math.Float32frombits(atomic.LoadUint32((*uint32)(unsafe.Pointer(&n.weight)))))
+}
+
+// addWeight atomically adds v to the weight of n.
+func (n *TreeNode) addWeight(v Value) {
+	addr := (*uint32)(unsafe.Pointer(&n.weight))
+	for {
+		old := atomic.LoadUint32(addr)
+		if atomic.CompareAndSwapUint32(addr, old, math.Float32bits(math.Float32frombits(old)+float32(v))) {
+			return
+		}
+	}
+}
+
+// Proof returns the proven value of n from the perspective of the root.
+// Proof returns Win or Loss for solved nodes and 0 otherwise.
+func (n *TreeNode) Proof() Value {
+	return Value(atomic.LoadInt32(&n.proof))
+}
+
+func (n *TreeNode) setProof(v Value) {
+	atomic.StoreInt32(&n.proof, int32(v))
+}
+
+// updateProof proves n from its children and reports whether n became proven.
+// n is a win for the side to move if any child is a win for it and a loss if all children are losses.
+// Steps within a turn keep the side to move, so proofs propagate through multi-step turns.
+func (n *TreeNode) updateProof() bool {
+	n.m.Lock()
+	defer n.m.Unlock()
+	if !n.expanded || len(n.children) == 0 || n.Proof() != 0 {
+		return false
+	}
+	lost := true
+	for _, c := range n.children {
+		switch proof := c.Proof(); n.side * proof {
+		case Win:
+			n.setProof(proof)
+			n.value = proof
+			return true
+		case 0:
+			lost = false
+		}
+	}
+	if !lost {
+		return false
+	}
+	n.setProof(n.side * Loss)
+	n.value = n.side * Loss
+	return true
+}
+
+// propagateProof updates the proofs of the ancestors of the proven node n.
+func (n *TreeNode) propagateProof() {
+	for a := n.parent; a != nil && a.updateProof(); a = a.parent {
+	}
 }
 
 // Policy returns the step policy for this node.
@@ -216,24 +455,85 @@ func (n *TreeNode) RunsLogits() []float32 {
 	return logits
 }
 
-// rootify resets this node to create an expanded root node.
+// child returns the child of n reached by step or pass or nil if there is none.
+func (n *TreeNode) child(step Step, pass bool) *TreeNode {
+	for _, c := range n.children {
+		if c.pass == pass && (pass || c.step == step) {
+			return c
+		}
+	}
+	return nil
+}
+
+// rootify makes n an expanded root node.
+// The subtree under n is converted to the perspective of n and n is expanded if needed.
 func (n *TreeNode) rootify(p *Pos, model ModelInterface) {
+	side := n.side
 	n.step = 0
-	n.side = 1
-	n.first = true
+	n.pass = false
 	n.parent = nil
-	n.children = n.children[:0]
-	n.Expand(p, model)
+	n.virtualLoss = 0
+	n.reroot(side, true)
+	if !n.expanded {
+		n.Expand(p, model)
+	}
+}
+
+// reroot converts the subtree under n to the perspective of a new root whose side
+// was side in the old tree. first is whether n is in the first turn of the new root.
+func (n *TreeNode) reroot(side Value, first bool) {
+	n.side *= side
+	n.first = first
+	if side < 0 {
+		n.weight = -n.weight
+		n.value = -n.value
+		n.proof = -n.proof
+	}
+	for _, c := range n.children {
+		c.reroot(side, first && !c.pass && c.side*side == n.side)
+	}
 }
 
 // Expand expands the node by generating all legal child nodes from this position.
 // All generated children are added to the frontier while n is removed from the frontier.
+// If n was already expanded, as happens with terminal nodes or when another goroutine
+// expanded n first, the value from its expansion is backpropagated again.
 func (n *TreeNode) Expand(p *Pos, model ModelInterface) {
-	v := p.Terminal()
-	if v.Terminal() {
-		n.Backprop(n.side*Loss, 1)
+	n.m.Lock()
+	if n.expanded {
+		v := n.value
+		n.m.Unlock()
+		n.Backprop(v, 1)
 		return
 	}
+	n.expanded = true
+	v, runs := n.expand(p, model)
+	n.value = v
+	n.m.Unlock()
+	n.Backprop(v, runs)
+	if n.Proof() != 0 {
+		n.propagateProof()
+	}
+}
+
+// expand generates children and evaluates n returning the value and runs to backprop.
+// n.m must be held.
+func (n *TreeNode) expand(p *Pos, model ModelInterface) (Value, uint32) {
+	if r := p.Terminal(); r.Terminal() {
+		// Terminal is from the perspective of the side to move.
+		n.setProof(n.side * r.Value)
+		return n.side * r.Value, 1
+	}
+	// The goal search result stays on p for BestMove.
+	// A node later in the turn has no goal when its parent had none,
+	// since the parent's search covered the step to n.
+	if n.parent == nil || n.parent.side != n.side {
+		if _, ok := p.Goal(); ok {
+			// The side to move goals this turn.
+			n.setProof(n.side * Win)
+			return n.side * Win, 1
+		}
+	}
 
 	// Pos is not at n.
 	// Generate pseudo-legal steps.
@@ -256,7 +556,7 @@ func (n *TreeNode) Expand(p *Pos, model ModelInterface) {
 	}
 
 	// Handle passing step:
-	if n.t.p.CanPass() {
+	if p.CanPass() {
 		hasChildren = true
 		child := n.t.NewTreeNode(n, 0, true, -n.side, false)
 		n.children = append(n.children, child)
@@ -264,50 +564,100 @@ func (n *TreeNode) Expand(p *Pos, model ModelInterface) {
 
 	if !hasChildren {
 		// No moves, losing node:
-		n.Backprop(n.side*Loss, 1)
-		return
+		n.setProof(n.side * Loss)
+		return n.side * Loss, 1
 	}
 
 	// Do backprop.
-	var runs = uint32(1)
+	n.t.m.Lock()
 	if e, found := n.t.tt.Probe(p.Hash()); found {
 		// TT Hit:
 		copy(n.policy, e.Policy)
-		v = e.Weight
-		runs = e.Runs
-	} else {
-		// TT Miss. Evaluate new node:
-		model.EvaluatePosition(p)
-		v = n.side * Value(model.Value())
-		model.Policy(n.policy)
-
-		// Save to tt.
-		e.Save(p.Hash(), n.t.tt.GlobalAge(), v, 1, n.policy)
+		v, runs := n.side*e.Weight, e.Runs
+		n.t.m.Unlock()
+		n.computePriors()
+		return v, runs
 	}
-	n.Backprop(v, runs)
+	n.t.m.Unlock()
+
+	// TT Miss. Evaluate new node:
+	values, policies := model.EvaluateBatch([]*Pos{p})
+	copy(n.policy, policies[0])
+	n.computePriors()
+
+	// Save to tt from the perspective of the side to move
+	// so entries stay valid when the root changes sides.
+	n.t.m.Lock()
+	e, _ := n.t.tt.Probe(p.Hash())
+	e.Save(p.Hash(), n.t.tt.GlobalAge(), Value(values[0]), 1, n.policy)
+	n.t.m.Unlock()
+	return n.side * Value(values[0]), 1
 }
 
-const c = 1.41421
-
-func (n *TreeNode) computePriority(deltaN uint32) {
-	var x float64
-	if n.HasParent() {
-		N := n.ParentRuns() + deltaN
-		x = c * math.Sqrt(math.Log(float64(N))/float64(1+n.Runs()))
+// computePriors sets the prior of each child to the softmax of the policy logits over the children of n.
+// n.m must be held.
+func (n *TreeNode) computePriors() {
+	max := float32(math.Inf(-1))
+	for _, c := range n.children {
+		if l := n.policy[c.StepIndex()]; l > max {
+			max = l
+		}
+	}
+	var sum float64
+	for _, c := range n.children {
+		x := math.Exp(float64(n.policy[c.StepIndex()] - max))
+		c.prior = float32(x)
+		sum += x
+	}
+	for _, c := range n.children {
+		c.prior = float32(float64(c.prior) / sum)
 	}
-	n.priority = x + float64(n.Weight()) + float64(n.policy[n.StepIndex()])
 }
 
-const largeBackprop = 1000000000
+// computePriority computes the PUCT selection priority of n:
+//
+//	Q + cpuct * P * sqrt(N) / (1 + n)
+//
+// where Q is the mean value of n for the side choosing at the parent, P is the prior
+// of n, N is the parent runs and n is the runs of n. Unvisited nodes get the parent's
+// mean value reduced by the first play urgency. The parent of n must be locked.
+// In-flight selections through n count as additional runs with a loss each (virtual loss).
+func (n *TreeNode) computePriority() {
+	if proof := n.Proof(); proof != 0 {
+		// Always choose a proven win and never a proven loss.
+		n.priority = math.Inf(int(n.parent.side * proof))
+		return
+	}
+	var (
+		parent = n.parent
+		vl     = float64(atomic.LoadInt32(&n.virtualLoss))
+		runs   = float64(n.Runs())
+		N      = float64(parent.Runs())
+		q      float64
+	)
+	if runs == 0 {
+		var parentQ float64
+		if N > 0 {
+			parentQ = float64(parent.side*parent.Weight()) / N
+		}
+		q = (parentQ - n.t.fpu - vl) / (1 + vl)
+	} else {
+		q = (float64(parent.side*n.Weight()) - vl) / (runs + vl)
+	}
+	u := n.t.cpuct * float64(n.prior) * math.Sqrt(math.Max(N, 1)) / (1 + runs + vl)
+	n.priority = q + u
+}
 
 // Backprop propagates the value v representing n runs to parents of this node.
-// Fixes the frontier heap.
+// It also removes the virtual loss applied by Select along the path.
+// Backprop is safe to call concurrently.
 func (n *TreeNode) Backprop(v Value, runs uint32) {
-	n.weight += v
-	n.runs += runs
-	for p := n.parent; p != nil; p = p.parent {
-		p.weight += v
-		p.runs += runs
+	for p := n; p != nil; p = p.parent {
+		p.addWeight(v)
+		atomic.AddUint32(&p.runs, runs)
+		if p.parent != nil {
+			atomic.AddInt32(&p.virtualLoss, -1)
+		}
 	}
 }
 
@@ -317,6 +667,19 @@ func (a byRuns) Len() int           { return len(a) }
 func (a byRuns) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a byRuns) Less(i, j int) bool { return a[i].Runs() > a[j].Runs() }
 
+// byProof orders proven wins first and proven losses last.
+type byProof []*TreeNode
+
+func (a byProof) Len() int           { return len(a) }
+func (a byProof) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byProof) Less(i, j int) bool { return a[i].Proof() > a[j].Proof() }
+
+type byPrior []*TreeNode
+
+func (a byPrior) Len() int           { return len(a) }
+func (a byPrior) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byPrior) Less(i, j int) bool { return a[i].prior > a[j].prior }
+
 type byPriority []*TreeNode
 
 func (a byPriority) Len() int           { return len(a) }
@@ -0,0 +1,276 @@
+package zoo
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+// newTestEngine creates an engine at the opening position which searches the given number of playouts.
+func newTestEngine(t *testing.T, playouts int) *Engine {
+	t.Helper()
+	engine, err := NewEngine(&EngineSettings{Seed: 1337}, &AEISettings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.ExecuteSetOption(fmt.Sprintf("name playouts value %d", playouts)); err != nil {
+		t.Fatal(err)
+	}
+	engine.timeInfo = nil
+	p, err := ParseShortPosition("g [rrrrrrrrhdcemcdh                                HDCMECDHRRRRRRRR]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.Pos = p
+	return engine
+}
+
+func TestTreeAdvance(t *testing.T) {
+	const playouts = 400
+	engine := newTestEngine(t, playouts)
+	engine.GoWait()
+	m := engine.bestMove
+	if m == nil {
+		t.Fatal("GoWait(): got no best move")
+	}
+	_, _, n, ok := engine.tree.BestMove(nil, nil)
+	if !ok {
+		t.Fatal("BestMove(): got no best move")
+	}
+	runs, weight := n.Runs(), n.Weight()
+	if runs == 0 {
+		t.Fatalf("BestMove(): got node with no runs")
+	}
+
+	engine.MakeMove(m)
+	root := engine.tree.Root()
+	if root != n {
+		t.Fatalf("MakeMove(%s): root is not the node of the best move", m)
+	}
+	if got := root.Runs(); got != runs {
+		t.Errorf("MakeMove(%s): got root runs %d, want %d", m, got, runs)
+	}
+	// Silver is to move so values are negated for the new root.
+	if got := root.Weight(); got != -weight {
+		t.Errorf("MakeMove(%s): got root weight %v, want %v", m, got, -weight)
+	}
+	if root.side != 1 || !root.first || root.parent != nil {
+		t.Errorf("MakeMove(%s): got root side=%v first=%v parent=%v, want side=1 first=true parent=nil", m, root.side, root.first, root.parent)
+	}
+	for _, c := range root.children {
+		if want := !c.pass && c.side == root.side; c.first != want {
+			t.Errorf("MakeMove(%s): got child %s first=%v, want %v", m, c.step, c.first, want)
+		}
+	}
+
+	// The next search continues from the retained runs.
+	engine.GoWait()
+	if got, want := engine.tree.Root(), n; got != want {
+		t.Fatalf("GoWait(): root was replaced")
+	}
+	if got, want := n.Runs(), runs+playouts; got < want {
+		t.Errorf("GoWait(): got root runs %d, want at least %d", got, want)
+	}
+}
+
+func TestTreeAdvanceNotFound(t *testing.T) {
+	engine := newTestEngine(t, 10)
+	engine.GoWait()
+
+	// A setup of pieces not in the position is never in the tree.
+	m, err := ParseMove("Ea1n Ea2n Ea3n Ea4n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := engine.Pos.Clone()
+	if engine.tree.Advance(m, p) {
+		t.Fatalf("Advance(%s): got found, want not found", m)
+	}
+	if root := engine.tree.Root(); root != nil {
+		t.Errorf("Advance(%s): got root %v, want reset tree", m, root)
+	}
+	engine.GoWait()
+	if engine.tree.Root() == nil || engine.tree.Root().Runs() == 0 {
+		t.Errorf("GoWait(): got empty tree after reset")
+	}
+}
+
+func TestComputePriors(t *testing.T) {
+	tree := NewEmptyTree(nil)
+	n := tree.NewTreeNode(nil, 0, false, 1, true)
+	for _, s := range []Step{MakeStep(GRabbit, A2, A3), MakeStep(GRabbit, B2, B3)} {
+		n.children = append(n.children, tree.NewTreeNode(n, s, false, 1, true))
+	}
+	n.children = append(n.children, tree.NewTreeNode(n, 0, true, -1, false))
+	for i := range n.policy {
+		n.policy[i] = 100 // logits of steps which are not children are ignored.
+	}
+	n.policy[n.children[0].StepIndex()] = 2
+	n.policy[n.children[1].StepIndex()] = 1
+	n.policy[passIndex] = 1
+	n.computePriors()
+
+	want := []float64{
+		math.E / (math.E + 2),
+		1 / (math.E + 2),
+		1 / (math.E + 2),
+	}
+	for i, c := range n.children {
+		if got := float64(c.prior); math.Abs(got-want[i]) > 1e-6 {
+			t.Errorf("computePriors(): got prior %d = %v, want %v", i, got, want[i])
+		}
+	}
+}
+
+func TestComputePriority(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		parentSide  Value
+		parentRuns  uint32
+		parentValue Value // mean value of the parent.
+		prior       float32
+		runs        uint32
+		value       Value // mean value of the node.
+		virtualLoss int32
+		want        float64
+	}{{
+		name:        "unvisited uses first play urgency",
+		parentSide:  1,
+		parentRuns:  4,
+		parentValue: 0.5,
+		prior:       0.5,
+		want:        0.5 - defaultFPU + defaultCPuct*0.5*2,
+	}, {
+		name:        "visited uses mean value",
+		parentSide:  1,
+		parentRuns:  16,
+		parentValue: 0.5,
+		prior:       0.25,
+		runs:        3,
+		value:       0.25,
+		want:        0.25 + defaultCPuct*0.25*4/4,
+	}, {
+		name:       "mean value for the side to move",
+		parentSide: -1,
+		parentRuns: 16,
+		prior:      0.25,
+		runs:       3,
+		value:      0.25,
+		want:       -0.25 + defaultCPuct*0.25*4/4,
+	}, {
+		name:        "virtual loss",
+		parentSide:  1,
+		parentRuns:  16,
+		prior:       0.25,
+		runs:        3,
+		value:       0.25,
+		virtualLoss: 1,
+		want:        (0.75-1)/4 + defaultCPuct*0.25*4/5,
+	}, {
+		name:       "unexpanded parent",
+		parentSide: 1,
+		prior:      1,
+		want:       -defaultFPU + defaultCPuct,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			tree := NewEmptyTree(nil)
+			parent := tree.NewTreeNode(nil, 0, false, tc.parentSide, true)
+			parent.runs = tc.parentRuns
+			parent.weight = tc.parentValue * Value(tc.parentRuns)
+			n := tree.NewTreeNode(parent, MakeStep(GRabbit, A2, A3), false, 1, true)
+			n.prior = tc.prior
+			n.runs = tc.runs
+			n.weight = tc.value * Value(tc.runs)
+			n.virtualLoss = tc.virtualLoss
+			n.computePriority()
+			if math.Abs(n.priority-tc.want) > 1e-6 {
+				t.Errorf("computePriority(): got %v, want %v", n.priority, tc.want)
+			}
+		})
+	}
+}
+
+func TestPUCTOptions(t *testing.T) {
+	engine := newTestEngine(t, 10)
+	for _, s := range []string{"name cpuct value 2.5", "name fpu value 0"} {
+		if err := engine.ExecuteSetOption(s); err != nil {
+			t.Fatalf("ExecuteSetOption(%q): %v", s, err)
+		}
+	}
+	engine.GoWait()
+	if engine.tree.cpuct != 2.5 || engine.tree.fpu != 0 {
+		t.Errorf("GoWait(): got cpuct=%v fpu=%v, want cpuct=2.5 fpu=0", engine.tree.cpuct, engine.tree.fpu)
+	}
+	if err := engine.ExecuteSetOption("name cpuct value x"); err == nil {
+		t.Errorf("ExecuteSetOption(cpuct x): got nil error, want error")
+	}
+}
+
+func TestUpdateProof(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		side   Value
+		proofs []Value // proofs of the children.
+		want   Value
+	}{
+		{"winning child", 1, []Value{0, Win}, Win},
+		{"losing children", 1, []Value{Loss, Loss}, Loss},
+		{"unknown child", 1, []Value{Loss, 0}, 0},
+		{"opponent wins", -1, []Value{Win, Loss}, Loss},
+		{"opponent loses", -1, []Value{Win, Win}, Win},
+		{"opponent unknown child", -1, []Value{Win, 0}, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tree := NewEmptyTree(nil)
+			n := tree.NewTreeNode(nil, 0, false, tc.side, tc.side == 1)
+			n.expanded = true
+			for i, proof := range tc.proofs {
+				c := tree.NewTreeNode(n, MakeStep(GRabbit, A2+Square(i), A3+Square(i)), false, tc.side, tc.side == 1)
+				c.setProof(proof)
+				n.children = append(n.children, c)
+			}
+			if got := n.updateProof(); got != (tc.want != 0) {
+				t.Errorf("updateProof(): got %v, want %v", got, tc.want != 0)
+			}
+			if got := n.Proof(); got != tc.want {
+				t.Errorf("updateProof(): got proof %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSolverGoal(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		pos  string
+	}{{
+		name: "one step",
+		pos:  "g [        R      r               e                            E   ]",
+	}, {
+		name: "two steps",
+		pos:  "g [               rR              e                            E   ]",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			engine := newTestEngine(t, 5000)
+			p, err := ParseShortPosition(tc.pos)
+			if err != nil {
+				t.Fatal(err)
+			}
+			engine.Pos = p
+			engine.GoWait()
+			root := engine.tree.Root()
+			if got := root.Proof(); got != Win {
+				t.Errorf("GoWait(): got root proof %v, want %v", got, Win)
+			}
+			// The search stops once the root is proven.
+			if runs := root.Runs(); runs >= 5000 {
+				t.Errorf("GoWait(): got %d runs, want fewer than the playout limit", runs)
+			}
+			m := engine.bestMove
+			engine.MakeMove(m)
+			if got := engine.Pos.Terminal().Value; got != Loss {
+				t.Errorf("MakeMove(%s): got silver value %v, want %v", m, got, Loss)
+			}
+		})
+	}
+}
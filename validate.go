@@ -0,0 +1,86 @@
+package zoo
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Reasons for a step or move to be illegal.
+// Errors returned by ValidateMove wrap one of these.
+var (
+	ErrEmptyMove      = errors.New("no steps taken")
+	ErrTooManySteps   = errors.New("too many steps")
+	ErrSetup          = errors.New("illegal setup")
+	ErrNoPiece        = errors.New("piece not on source square")
+	ErrOccupied       = errors.New("destination occupied")
+	ErrNotAdjacent    = errors.New("destination not adjacent")
+	ErrFrozen         = errors.New("piece frozen")
+	ErrBackwardRabbit = errors.New("rabbit steps backward")
+	ErrBadPush        = errors.New("illegal push or pull")
+	ErrCapture        = errors.New("wrong or missing capture")
+	ErrRepetition     = errors.New("third repetition of position")
+	ErrNoChange       = errors.New("position unchanged")
+)
+
+// MoveError describes why a move is illegal.
+type MoveError struct {
+	Move Move
+	Step Step // offending step or 0 if the turn cannot end after the move.
+	Err  error
+}
+
+func (e *MoveError) Error() string {
+	if e.Step == 0 {
+		return fmt.Sprintf("illegal move %q: %v", e.Move, e.Err)
+	}
+	return fmt.Sprintf("illegal move %q at %s: %v", e.Move, e.Step, e.Err)
+}
+
+func (e *MoveError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateMove checks that m completes the current turn legally and returns a *MoveError if not.
+// Steps which result in a capture must be followed by the capture step and m must not contain
+// other captures. p is not modified.
+func (p *Pos) ValidateMove(m Move) error {
+	_, err := p.replayMove(m, true)
+	return err
+}
+
+// replayMove plays m on a clone of p and returns the steps played including the captures
+// which result. Capture steps in m must match these captures if strict is set and are
+// ignored otherwise.
+func (p *Pos) replayMove(m Move, strict bool) (Move, error) {
+	q := p.Clone()
+	c := q.Side()
+	res := make(Move, 0, len(m))
+	for i := 0; i < len(m); i++ {
+		s := m[i]
+		if s.Capture() && !strict {
+			continue
+		}
+		if q.Side() != c {
+			return nil, &MoveError{m, s, ErrTooManySteps}
+		}
+		if err := q.checkStep(s); err != nil {
+			return nil, &MoveError{m, s, err}
+		}
+		res = append(res, s)
+		if cap := q.Step(s); cap.Capture() {
+			res = append(res, cap)
+			if strict {
+				if i+1 == len(m) || m[i+1] != cap {
+					return nil, &MoveError{m, s, ErrCapture}
+				}
+				i++
+			}
+		}
+	}
+	if q.Side() == c {
+		if err := q.checkPass(); err != nil {
+			return nil, &MoveError{m, 0, err}
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,92 @@
+package zoo
+
+import (
+	"errors"
+	"testing"
+)
+
+// tacticsPosition has a frozen rabbit a2, a horse d7 the dog d6 cannot push,
+// and cats a6 and g6 which the elephants a5 and h6 can push. The trap f6 is unguarded.
+const tacticsPosition = "g [           h    c  D  cEE              R        Rd              ]"
+
+func TestValidateMove(t *testing.T) {
+	const opening = "g [rrrrrrrrhdcemcdh                                HDCMECDHRRRRRRRR]"
+	for _, tc := range []struct {
+		name    string
+		pos     string
+		history []string // moves played before the move.
+		move    string
+		want    error
+	}{
+		{"legal", opening, nil, "Ha2n Ha3n Ha4n", nil},
+		{"legal capture", tacticsPosition, nil, "cg6w cf6x Eh6w", nil},
+		{"legal pull", tacticsPosition, nil, "Eh6s cg6e", nil},
+		{"empty", opening, nil, "", ErrEmptyMove},
+		{"too many steps", opening, nil, "Ha2n Ha3n Ha4n Hh2n Hh3n", ErrTooManySteps},
+		{"setup", opening, nil, "Ra3", ErrSetup},
+		{"no piece", opening, nil, "Ea3n", ErrNoPiece},
+		{"wrong piece", opening, nil, "Ma2n", ErrNoPiece},
+		{"occupied", opening, nil, "Ra1n", ErrOccupied},
+		{"frozen", tacticsPosition, nil, "Ra2n", ErrFrozen},
+		{"backward rabbit", tacticsPosition, nil, "Rh4s", ErrBackwardRabbit},
+		{"push without pusher", tacticsPosition, nil, "hd7e", ErrBadPush},
+		{"abandoned push", tacticsPosition, nil, "ca6n Rh4n", ErrBadPush},
+		{"incomplete push", tacticsPosition, nil, "ca6n", ErrBadPush},
+		{"missing capture", tacticsPosition, nil, "cg6w Eh6w", ErrCapture},
+		{"wrong capture", tacticsPosition, nil, "cg6w cg6x Eh6w", ErrCapture},
+		{"extra capture", tacticsPosition, nil, "Eh6s cf6x", ErrCapture},
+		{"no change", opening, nil, "Ha2n Ha3s", ErrNoChange},
+		{"repetition", opening, []string{
+			"Ha2n", "ha7s", "Ha3s", "ha6n",
+			"Ha2n", "ha7s", "Ha3s",
+		}, "ha6n", ErrRepetition},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := ParseShortPosition(tc.pos)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tc.history {
+				m, err := ParseMove(s)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := p.ValidateMove(m); err != nil {
+					t.Fatalf("ValidateMove(%s): %v", s, err)
+				}
+				p.Move(m)
+			}
+			m, err := ParseMove(tc.move)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := p.FullString()
+			err = p.ValidateMove(m)
+			if !errors.Is(err, tc.want) || (err == nil) != (tc.want == nil) {
+				t.Errorf("ValidateMove(%s): got error %v, want %v", tc.move, err, tc.want)
+			}
+			var moveErr *MoveError
+			if err != nil && !errors.As(err, &moveErr) {
+				t.Errorf("ValidateMove(%s): got error %T, want *MoveError", tc.move, err)
+			}
+			if got := p.FullString(); got != want {
+				t.Errorf("ValidateMove(%s): got position %s, want unchanged %s", tc.move, got, want)
+			}
+		})
+	}
+}
+
+func TestMakeMoveIllegal(t *testing.T) {
+	engine := newTestEngine(t, 10)
+	want := engine.Pos.FullString()
+	err := engine.ExecuteCommand("makemove Ha2n Ha3s")
+	if !errors.Is(err, ErrNoChange) {
+		t.Errorf("makemove Ha2n Ha3s: got error %v, want %v", err, ErrNoChange)
+	}
+	if got := engine.Pos.FullString(); got != want {
+		t.Errorf("makemove Ha2n Ha3s: got position %s, want unchanged %s", got, want)
+	}
+	if err := engine.ExecuteCommand("makemove Ha2n Ha3n"); err != nil {
+		t.Errorf("makemove Ha2n Ha3n: %v", err)
+	}
+}
@@ -1,9 +1,16 @@
 package zoo
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"time"
 
 	zoopb "github.com/ajzaff/bot_zoo/proto"
 	"github.com/golang/protobuf/proto"
@@ -12,26 +19,182 @@ import (
 
 const gamesPerBatch = 100
 
+// defaultDatasetDir is the directory Dataset files are written to when none is given.
+var defaultDatasetDir = filepath.Join("data", "training")
+
+// Types of records in the game log of a BatchWriter.
+// Each record is the type followed by the uvarint length of the payload and the payload.
+const (
+	logGame  byte = 'g' // payload is a finished Match_Game.
+	logBatch byte = 'b' // payload is the uvarint number of the batch file written and the uvarint games finished.
+)
+
+// maxLogRecord bounds the payload of a game log record so that a corrupt length is detected.
+const maxLogRecord = 1 << 28
+
 // BatchWriter implements a writer capable of outputting training data.
+// Batches of games are written to files named after the epoch, run ID and batch number.
+// Files are written atomically, and each finished game is first appended to a log so that
+// the games of an interrupted run are recovered by a BatchWriter with the same run ID.
+// The log is cut back to the last batch record whenever a batch file is written.
 type BatchWriter struct {
 	dir   string
+	name  string // file name prefix including the epoch and run ID
 	epoch int
+	log   *os.File // append-only log of finished games and written batches
 
+	games       int               // games finished in this run including recovered games
 	batchNumber int               // batch number
 	inProgress  *zoopb.Match_Game // in progress game
 	buffered    *zoopb.Match      // buffered games
 	finished    *zoopb.Match      // finished games
 }
 
-// NewBatchWriter creates a new BatchWriter with the given batch size in number of games per Dataset file.
-func NewBatchWriter(epoch int) *BatchWriter {
-	return &BatchWriter{
-		dir:        filepath.Join("data", "training"),
+// NewRunID returns a run ID which is unique with high probability.
+func NewRunID() string {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	return fmt.Sprintf("%s-%04x", time.Now().Format("20060102T150405"), r.Intn(1<<16))
+}
+
+// NewBatchWriter creates a BatchWriter which writes Dataset files of up to 100 games to dir.
+// dir is created if needed and defaults to data/training. Games finished by a previous
+// BatchWriter of the same epoch and run ID which were not written to a file are recovered.
+func NewBatchWriter(dir string, epoch int, runID string) (*BatchWriter, error) {
+	return newBatchWriter(dir, epoch, fmt.Sprintf("games_e%d_%s", epoch, runID))
+}
+
+// NewShardedBatchWriter is like NewBatchWriter for one of several writers of the same run.
+// File names include the shard name so that shards do not overwrite each other's files.
+func NewShardedBatchWriter(dir string, epoch int, runID, shard string) (*BatchWriter, error) {
+	return newBatchWriter(dir, epoch, fmt.Sprintf("games_e%d_%s_%s", epoch, runID, shard))
+}
+
+func newBatchWriter(dir string, epoch int, name string) (*BatchWriter, error) {
+	if dir == "" {
+		dir = defaultDatasetDir
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	w := &BatchWriter{
+		dir:        dir,
+		name:       name,
 		epoch:      epoch,
 		inProgress: &zoopb.Match_Game{Pgn: &zoopb.PGN{}},
 		buffered:   &zoopb.Match{},
 		finished:   &zoopb.Match{},
 	}
+	if err := w.recover(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// recover opens the game log and restores the finished games which were not written to a file.
+// A partial record left by a crash is discarded.
+func (w *BatchWriter) recover() error {
+	f, err := os.OpenFile(filepath.Join(w.dir, w.name+".log"), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	r := bufio.NewReader(f)
+	var valid int64 // offset after the last complete record.
+loop:
+	for {
+		typ, payload, n, err := readLogRecord(r)
+		if err != nil {
+			break
+		}
+		switch typ {
+		case logGame:
+			g := &zoopb.Match_Game{}
+			if err := proto.Unmarshal(payload, g); err != nil {
+				break loop
+			}
+			w.finished.Games = append(w.finished.Games, g)
+			w.games++
+		case logBatch:
+			batch, k := binary.Uvarint(payload)
+			if k <= 0 {
+				break loop
+			}
+			w.batchNumber = int(batch) + 1
+			w.finished = &zoopb.Match{}
+			// Logs cut back to the batch record keep the number of games before it.
+			if games, k := binary.Uvarint(payload[k:]); k > 0 {
+				w.games = int(games)
+			}
+		default:
+			break loop
+		}
+		valid += n
+	}
+	if err := f.Truncate(valid); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Seek(valid, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+	w.log = f
+	return nil
+}
+
+// readLogRecord reads a game log record from r and returns its type, payload and size.
+func readLogRecord(r *bufio.Reader) (typ byte, payload []byte, n int64, err error) {
+	if typ, err = r.ReadByte(); err != nil {
+		return 0, nil, 0, err
+	}
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, nil, 0, err
+	}
+	if size > maxLogRecord {
+		return 0, nil, 0, fmt.Errorf("game log record of %d bytes", size)
+	}
+	payload = make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, 0, err
+	}
+	var buf [binary.MaxVarintLen64]byte
+	return typ, payload, 1 + int64(binary.PutUvarint(buf[:], size)) + int64(size), nil
+}
+
+// logRecord returns the encoded game log record.
+func logRecord(typ byte, payload []byte) []byte {
+	buf := make([]byte, 1, 1+binary.MaxVarintLen64+len(payload))
+	buf[0] = typ
+	buf = binary.AppendUvarint(buf, uint64(len(payload)))
+	return append(buf, payload...)
+}
+
+// appendLog appends a record to the game log and syncs it to disk.
+func (w *BatchWriter) appendLog(typ byte, payload []byte) error {
+	if _, err := w.log.Write(logRecord(typ, payload)); err != nil {
+		return err
+	}
+	return w.log.Sync()
+}
+
+// resetLog atomically replaces the game log with a single record.
+func (w *BatchWriter) resetLog(typ byte, payload []byte) error {
+	path := filepath.Join(w.dir, w.name+".log")
+	if err := writeFileAtomic(path, logRecord(typ, payload)); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	w.log.Close()
+	w.log = f
+	return nil
+}
+
+// Games returns the number of games finished in this run including recovered games.
+func (w *BatchWriter) Games() int {
+	return w.games
 }
 
 // WriteExample writes the example trajectory to the buffer.
@@ -49,51 +212,85 @@ func (w *BatchWriter) WriteExample(p *Pos, t *Tree) {
 	})
 }
 
-// write writes the buffered examples to a Dataset file.
-// Format of a single record:
-//  uint64    length
-//  uint32    masked crc of length
-//  byte      data[length]
-//  uint32    masked crc of data
-func (w *BatchWriter) write() (err error) {
-	f, err := os.OpenFile(filepath.Join(w.dir, fmt.Sprintf("games%d.pb.snappy", w.batchNumber)), os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0755)
+// write writes the finished games to the next Dataset file and records it in the game log.
+func (w *BatchWriter) write() error {
+	payload, err := proto.Marshal(w.finished)
 	if err != nil {
 		return err
 	}
-	defer func() {
-		err1 := f.Close()
-		if err == nil {
-			err = err1
-		}
-	}()
-	sw := snappy.NewWriter(f)
-
-	payload, err := proto.Marshal(w.finished)
-	if err != nil {
+	var buf bytes.Buffer
+	sw := snappy.NewWriter(&buf)
+	if _, err := sw.Write(payload); err != nil {
 		return err
 	}
-
-	_, err = sw.Write(payload)
-	if err != nil {
+	path := filepath.Join(w.dir, fmt.Sprintf("%s_%d.pb.snappy", w.name, w.batchNumber))
+	if err := writeFileAtomic(path, buf.Bytes()); err != nil {
+		return err
+	}
+	// Games are rewritten to the same file if we crash before the batch is logged.
+	// The games of the log are in the file now so the log only keeps the batch record.
+	record := binary.AppendUvarint(nil, uint64(w.batchNumber))
+	record = binary.AppendUvarint(record, uint64(w.games))
+	if err := w.resetLog(logBatch, record); err != nil {
 		return err
 	}
-
 	w.batchNumber++
 	w.finished = &zoopb.Match{}
 	return nil
 }
 
+// writeFileAtomic writes data to a temporary file next to path and renames it to path
+// so that path never holds a partial file.
+func writeFileAtomic(path string, data []byte) (err error) {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	if err := f.Chmod(0644); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
 // Finalize is called after the game has completed with the result for the given side.
 // The method updates all examples in memory with the final score and commits them to
-// the finished examples.
+// the finished examples. The game is appended to the game log before it is buffered.
 func (w *BatchWriter) Finalize(p *Pos, t Value) error {
 	if p.Side() == Silver {
 		t = -t
 	}
-	w.inProgress.Pgn.Result = int32(t)
-	w.inProgress.Pgn.Pgn = p.MoveList().String()
-	w.finished.Games = append(w.finished.Games, w.inProgress)
+	g := w.inProgress
 	w.inProgress = &zoopb.Match_Game{Pgn: &zoopb.PGN{}}
+	l, err := p.MoveList().Normalize()
+	if err != nil {
+		return err
+	}
+	g.Pgn.Result = int32(t)
+	g.Pgn.Pgn = l.String()
+	payload, err := proto.Marshal(g)
+	if err != nil {
+		return err
+	}
+	if err := w.appendLog(logGame, payload); err != nil {
+		return err
+	}
+	w.finished.Games = append(w.finished.Games, g)
+	w.games++
 	if len(w.finished.Games) >= gamesPerBatch {
 		if err := w.write(); err != nil {
 			return err
@@ -111,3 +308,9 @@ func (w *BatchWriter) Flush() error {
 	}
 	return nil
 }
+
+// Close closes the game log. Games which were not flushed are recovered by the next
+// BatchWriter of the same run.
+func (w *BatchWriter) Close() error {
+	return w.log.Close()
+}
@@ -0,0 +1,198 @@
+package zoo
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	zoopb "github.com/ajzaff/bot_zoo/proto"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+)
+
+// newWriterTestPos returns a position after both setups.
+func newWriterTestPos(t *testing.T) *Pos {
+	t.Helper()
+	l, err := ParseMoveList(`1g Ra1 Rb1 Rc1 Rd1 Re1 Rf1 Rg1 Rh1 Ha2 Db2 Cc2 Md2 Ee2 Cf2 Dg2 Hh2
+1s ra8 rb8 rc8 rd8 re8 rf8 rg8 rh8 ha7 db7 cc7 ed7 me7 cf7 dg7 hh7`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewEmptyPosition()
+	for _, m := range l {
+		p.Move(m)
+	}
+	return p
+}
+
+// readBatch reads the games of the Dataset file at path.
+func readBatch(t *testing.T, path string) *zoopb.Match {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	bs, err := ioutil.ReadAll(snappy.NewReader(f))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &zoopb.Match{}
+	if err := proto.Unmarshal(bs, m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestBatchWriterFiles(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data", "training")
+	w, err := NewBatchWriter(dir, 2, "run1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newWriterTestPos(t)
+	for i := 0; i < 3; i++ {
+		if err := w.Finalize(p, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if m := readBatch(t, filepath.Join(dir, "games_e2_run1_0.pb.snappy")); len(m.Games) != 3 {
+		t.Errorf("Flush(): got %d games in batch 0, want 3", len(m.Games))
+	}
+	names, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		if strings.Contains(name, ".tmp") {
+			t.Errorf("Flush(): got temporary file %s left behind", name)
+		}
+	}
+}
+
+func TestBatchWriterRecover(t *testing.T) {
+	dir := t.TempDir()
+	p := newWriterTestPos(t)
+	w, err := NewBatchWriter(dir, 0, "run1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := w.Finalize(p, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Crash without flushing in the middle of logging a game.
+	w.Close()
+	f, err := os.OpenFile(filepath.Join(dir, "games_e0_run1.log"), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{logGame, 100, 1, 2})
+	f.Close()
+
+	w, err = NewBatchWriter(dir, 0, "run1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := w.Games(); got != 2 {
+		t.Errorf("NewBatchWriter(): got %d recovered games, want 2", got)
+	}
+	if err := w.Finalize(p, -1); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	if m := readBatch(t, filepath.Join(dir, "games_e0_run1_0.pb.snappy")); len(m.Games) != 3 {
+		t.Errorf("Flush(): got %d games in batch 0, want 3", len(m.Games))
+	}
+
+	// The log is cut back to the batch record once the batch is written.
+	bs, err := ioutil.ReadFile(filepath.Join(dir, "games_e0_run1.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := logRecord(logBatch, []byte{0, 3}); !bytes.Equal(bs, want) {
+		t.Errorf("Flush(): got log %q, want %q", bs, want)
+	}
+
+	// Written games are not recovered again.
+	w, err = NewBatchWriter(dir, 0, "run1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if w.Games() != 3 || w.batchNumber != 1 || len(w.finished.Games) != 0 {
+		t.Errorf("NewBatchWriter(): got %d games, batch %d and %d pending games, want 3 games, batch 1 and none pending",
+			w.Games(), w.batchNumber, len(w.finished.Games))
+	}
+}
+
+func TestBatchWriterRecoverFullBatch(t *testing.T) {
+	dir := t.TempDir()
+	p := newWriterTestPos(t)
+	w, err := NewShardedBatchWriter(dir, 1, "run1", "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i <= gamesPerBatch; i++ {
+		if err := w.Finalize(p, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Close()
+	if m := readBatch(t, filepath.Join(dir, "games_e1_run1_3_0.pb.snappy")); len(m.Games) != gamesPerBatch {
+		t.Errorf("Finalize(): got %d games in batch 0, want %d", len(m.Games), gamesPerBatch)
+	}
+
+	w, err = NewShardedBatchWriter(dir, 1, "run1", "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if w.Games() != gamesPerBatch+1 || w.batchNumber != 1 || len(w.finished.Games) != 1 {
+		t.Errorf("NewShardedBatchWriter(): got %d games, batch %d and %d pending games, want %d games, batch 1 and 1 pending",
+			w.Games(), w.batchNumber, len(w.finished.Games), gamesPerBatch+1)
+	}
+}
+
+func TestBatchWriterFinalizeError(t *testing.T) {
+	w, err := NewBatchWriter(t.TempDir(), 0, "run1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	// The move list of a position parsed after setup does not replay from the initial position.
+	bad, err := ParseShortPosition("g [rrrrrrrrhdcemcdh                                HDCMECDHRRRRRRRR]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := MakeStep(GRabbit, A2, A3)
+	w.WriteExample(bad, stepTree(step))
+	bad.Move(Move{step})
+	if err := w.Finalize(bad, 0); err == nil {
+		t.Fatalf("Finalize(): got nil error for a move list which does not replay, want error")
+	}
+
+	if err := w.Finalize(newWriterTestPos(t), 1); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(w.finished.Games); n != 1 {
+		t.Fatalf("Finalize(): got %d finished games, want 1", n)
+	}
+	if got := len(w.finished.Games[0].Pgn.Annotations); got != 0 {
+		t.Errorf("Finalize(): got %d examples of the failed game in the next game, want 0", got)
+	}
+}